@@ -0,0 +1,124 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ProbeSpec defines the desired state of Probe
+type ProbeSpec struct {
+
+	// Prober blackbox exporter probing the targets
+	Prober ProberSpec `json:"prober"`
+
+	// Module of the blackbox exporter used to probe the targets
+	// +optional
+	// +kubebuilder:default=http_2xx
+	Module string `json:"module,omitempty"`
+
+	// Interval between two probes of a target, the global scrape interval by default
+	// +optional
+	Interval string `json:"interval,omitempty"`
+
+	// ScrapeTimeout of a probe, the global scrape timeout by default
+	// +optional
+	ScrapeTimeout string `json:"scrapeTimeout,omitempty"`
+
+	// Targets probed, either static or discovered from Ingresses
+	Targets ProbeTargets `json:"targets"`
+}
+
+// ProberSpec defines the blackbox exporter probing the targets
+type ProberSpec struct {
+
+	// URL address of the blackbox exporter, as host:port
+	URL string `json:"url"`
+
+	// Scheme of the blackbox exporter
+	// +optional
+	// +kubebuilder:default=http
+	// +kubebuilder:validation:Enum=http;https
+	Scheme string `json:"scheme,omitempty"`
+
+	// Path of the probe endpoint of the blackbox exporter
+	// +optional
+	// +kubebuilder:default=/probe
+	Path string `json:"path,omitempty"`
+}
+
+// ProbeTargets defines the targets of a Probe
+type ProbeTargets struct {
+
+	// StaticConfig probes a static list of targets
+	// +optional
+	StaticConfig *ProbeStaticConfig `json:"staticConfig,omitempty"`
+
+	// Ingress probes the URLs of the selected Ingresses
+	// +optional
+	Ingress *ProbeIngress `json:"ingress,omitempty"`
+}
+
+// ProbeStaticConfig defines static probe targets
+type ProbeStaticConfig struct {
+
+	// Static targets, URLs or addresses depending on the module
+	// +kubebuilder:validation:MinItems=1
+	Static []string `json:"static"`
+
+	// Labels added to the probes of the targets
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// ProbeIngress defines the Ingresses whose URLs are probed
+type ProbeIngress struct {
+
+	// Selector of the Ingresses
+	// +optional
+	Selector metav1.LabelSelector `json:"selector,omitempty"`
+
+	// Namespaces of the Ingresses, the namespace of the Probe by default
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:printcolumn:name="Prober",type="string",JSONPath=".spec.prober.url",description="Blackbox exporter probing the targets"
+//+kubebuilder:printcolumn:name="Module",type="string",JSONPath=".spec.module",description="Module of the blackbox exporter"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Time duration since creation of Probe"
+
+// Probe is the Schema for the probes API
+type Probe struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ProbeSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ProbeList contains a list of Probe
+type ProbeList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Probe `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Probe{}, &ProbeList{})
+}
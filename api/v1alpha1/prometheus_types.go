@@ -18,7 +18,9 @@ package v1alpha1
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // PrometheusSpec defines the desired state of Prometheus
@@ -27,24 +29,229 @@ type PrometheusSpec struct {
 	// Image represent the spec of Prometheus image/version
 	Image ImageSpec `json:"image"`
 
+	// Mode of Prometheus, either a "server" or an "agent" only forwarding
+	// the scraped samples to RemoteWrite.
+	// +optional
+	// +kubebuilder:validation:Enum=server;agent
+	// +kubebuilder:default=server
+	Mode string `json:"mode,omitempty"`
+
 	// Replica number of replicas to run
 	Replicas int32 `json:"replicas"`
 
+	// Shards number of StatefulSets splitting the scrape targets between them,
+	// each scraping a disjoint subset of the targets of every job. With more than one
+	// shard, each shard sets the "shard" external label, which ExternalLabels cannot set.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=1
+	Shards *int32 `json:"shards,omitempty"`
+
+	// ShardRetentionPolicy of the PersistentVolumeClaims of shards removed by scaling down Shards.
+	// They are kept with "Retain" until the policy is set to "Delete".
+	// +optional
+	// +kubebuilder:validation:Enum=Retain;Delete
+	// +kubebuilder:default=Retain
+	ShardRetentionPolicy string `json:"shardRetentionPolicy,omitempty"`
+
+	// ImagePullPolicy of the Prometheus image
+	// +optional
+	// +kubebuilder:validation:Enum=Always;Never;IfNotPresent
+	// +kubebuilder:default=IfNotPresent
+	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy,omitempty"`
+
+	// ImagePullSecrets used to pull the images of the Prometheus pods.
+	// +optional
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
 	// Compute Resources for Prometheus.
 	// +optional
 	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
 
 	// VolumeClaimTemplate the claim that Prometheus reference.
+	// +optional
 	// +immutable
 	VolumeClaimTemplate corev1.PersistentVolumeClaim `json:"volumeClaimTemplate"`
 
+	// EphemeralStorage stores the data in an emptyDir volume instead of the VolumeClaimTemplate.
+	// In agent mode, the replicas are then run by a Deployment instead of a StatefulSet.
+	// +optional
+	EphemeralStorage bool `json:"ephemeralStorage,omitempty"`
+
+	// RemoteWrite endpoints the samples are sent to.
+	// +optional
+	RemoteWrite []RemoteWriteSpec `json:"remoteWrite,omitempty"`
+
 	// Targets Prometheus scraping targets
 	// +optional
 	Targets []PrometheusTarget `json:"targets,omitempty"`
 
+	// ScrapeTargetSelector selects the ScrapeTargets of any namespace whose target groups are
+	// merged with Targets. No ScrapeTarget is selected when unset.
+	// +optional
+	ScrapeTargetSelector *metav1.LabelSelector `json:"scrapeTargetSelector,omitempty"`
+
+	// ProbeSelector selects the Probes of any namespace translated into blackbox exporter jobs.
+	// No Probe is selected when unset.
+	// +optional
+	ProbeSelector *metav1.LabelSelector `json:"probeSelector,omitempty"`
+
+	// ScrapeTargetNamespaceSelector restricts the ScrapeTargets to the namespaces it selects.
+	// ScrapeTargets of any namespace are selected when unset.
+	// +optional
+	ScrapeTargetNamespaceSelector *metav1.LabelSelector `json:"scrapeTargetNamespaceSelector,omitempty"`
+
+	// ProbeNamespaceSelector restricts the Probes to the namespaces it selects. Probes of any
+	// namespace are selected when unset.
+	// +optional
+	ProbeNamespaceSelector *metav1.LabelSelector `json:"probeNamespaceSelector,omitempty"`
+
+	// EnforcedNamespaceLabel label set to the namespace of the ScrapeTarget or Probe on every
+	// series scraped from it, overriding the labels set by the object.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z_][a-zA-Z0-9_]*$`
+	EnforcedNamespaceLabel string `json:"enforcedNamespaceLabel,omitempty"`
+
+	// IgnoreNamespaceSelectors ignores the namespaces selected by the Probes, restricting the
+	// Ingresses they discover to their own namespace. ScrapeTargets list static targets and
+	// are not affected.
+	// +optional
+	IgnoreNamespaceSelectors bool `json:"ignoreNamespaceSelectors,omitempty"`
+
+	// FileSDConfigMaps ConfigMaps of the namespace of the Prometheus holding file_sd target
+	// groups, in JSON for .json keys and YAML for .yml and .yaml keys. Each ConfigMap is scraped
+	// by its own job, reading only the keys holding valid target groups. The validation only
+	// filters the files of the job: the whole ConfigMap is mounted, so a listed key edited to be
+	// invalid reaches Prometheus before the next reconcile drops it, and Prometheus keeps the
+	// last targets it could read from it meanwhile.
+	// +optional
+	FileSDConfigMaps []FileSDConfigMap `json:"fileSDConfigMaps,omitempty"`
+
 	// AdditionalScrapeConfigs Prometheus scraping configs
 	// +optional
 	AdditionalScrapeConfig []ScrapeConfig `json:"additionalScrapeConfigs,omitempty"`
+
+	// SelfMonitor adds a "prometheus" job scraping the metrics of every replica.
+	// +optional
+	SelfMonitor bool `json:"selfMonitor,omitempty"`
+
+	// Presets add the jobs monitoring the components of the cluster.
+	// +optional
+	Presets PresetsSpec `json:"presets,omitempty"`
+
+	// ScrapeConfigFiles paths of additional files holding scrape configs, mounted with Volumes
+	// and VolumeMounts. Requires Prometheus v2.43.0 or later.
+	// +optional
+	ScrapeConfigFiles []string `json:"scrapeConfigFiles,omitempty"`
+
+	// NativeHistograms enables the ingestion of native histograms.
+	// Requires Prometheus v2.40.0 or later.
+	// +optional
+	NativeHistograms bool `json:"nativeHistograms,omitempty"`
+
+	// NodeSelector constrains the Prometheus pods to nodes with matching labels.
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Tolerations of the Prometheus pods.
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// Affinity of the Prometheus pods. When no PodAntiAffinity is given here,
+	// the one computed from the PodAntiAffinity preset is used.
+	// +optional
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+
+	// PodAntiAffinity preset spreading the replicas of each shard across nodes and zones.
+	// "soft" prefers, "hard" requires replicas of a shard on distinct nodes.
+	// +optional
+	// +kubebuilder:validation:Enum=soft;hard
+	// +kubebuilder:default=soft
+	PodAntiAffinity string `json:"podAntiAffinity,omitempty"`
+
+	// TopologySpreadConstraints of the Prometheus pods.
+	// +optional
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+
+	// PriorityClassName of the Prometheus pods.
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+
+	// PodDisruptionBudget of the Prometheus replicas, one per shard. When not set, a budget
+	// with maxUnavailable 1 is created as soon as there is more than one replica.
+	// +optional
+	PodDisruptionBudget *PodDisruptionBudgetSpec `json:"podDisruptionBudget,omitempty"`
+
+	// SecurityContext of the Prometheus pods, replacing the restricted default
+	// (runAsNonRoot, runAsUser/fsGroup 65534, RuntimeDefault seccomp profile).
+	// +optional
+	SecurityContext *corev1.PodSecurityContext `json:"securityContext,omitempty"`
+
+	// ContainerSecurityContext of the Prometheus pod containers, replacing the restricted default
+	// (readOnlyRootFilesystem, no privilege escalation, all capabilities dropped).
+	// +optional
+	ContainerSecurityContext *corev1.SecurityContext `json:"containerSecurityContext,omitempty"`
+
+	// ConfigReloader defines how Prometheus is reloaded when its configuration changes.
+	// +optional
+	// +kubebuilder:default={strategy: sidecar}
+	ConfigReloader ConfigReloaderSpec `json:"configReloader,omitempty"`
+
+	// Containers additional containers of the Prometheus pods. A container named like a
+	// generated one ("prometheus", "configmap-reload") is strategic merged into it.
+	// +optional
+	Containers []corev1.Container `json:"containers,omitempty"`
+
+	// InitContainers of the Prometheus pods.
+	// +optional
+	InitContainers []corev1.Container `json:"initContainers,omitempty"`
+
+	// Volumes additional volumes of the Prometheus pods. A volume named like a generated one
+	// replaces it.
+	// +optional
+	Volumes []corev1.Volume `json:"volumes,omitempty"`
+
+	// VolumeMounts additional volume mounts of the prometheus container, merged by mount path
+	// with the generated ones.
+	// +optional
+	VolumeMounts []corev1.VolumeMount `json:"volumeMounts,omitempty"`
+
+	// ExternalLabels attached to any series or alerts leaving Prometheus.
+	// +optional
+	ExternalLabels map[string]string `json:"externalLabels,omitempty"`
+
+	// Web configuration of the Prometheus UI and API.
+	// +optional
+	Web *WebSpec `json:"web,omitempty"`
+
+	// Service customizes the client-facing Service of Prometheus. The StatefulSets are governed
+	// by a separate headless Service.
+	// +optional
+	// +kubebuilder:default={type: ClusterIP, sessionAffinity: ClientIP}
+	Service ServiceSpec `json:"service,omitempty"`
+
+	// Ingress exposes the Prometheus Service through an Ingress. Unless Web.ExternalURL is
+	// set, the external URL of Prometheus is derived from it.
+	// +optional
+	Ingress *IngressSpec `json:"ingress,omitempty"`
+
+	// HTTPRoute exposes the Prometheus Service through a Gateway API HTTPRoute, alternatively
+	// to Ingress. Unless Web.ExternalURL is set, the external URL of Prometheus is derived from it.
+	// +optional
+	HTTPRoute *HTTPRouteSpec `json:"httpRoute,omitempty"`
+
+	// NetworkPolicy generates a NetworkPolicy restricting the traffic of the Prometheus pods.
+	// +optional
+	NetworkPolicy *NetworkPolicySpec `json:"networkPolicy,omitempty"`
+
+	// TargetHealth polls the targets of every replica and summarizes their health in the status.
+	// +optional
+	TargetHealth *TargetHealthSpec `json:"targetHealth,omitempty"`
+
+	// Thanos adds a Thanos sidecar to the Prometheus pods, uploading blocks to object storage
+	// and serving the StoreAPI to a Thanos Querier. Requires ExternalLabels.
+	// +optional
+	Thanos *ThanosSpec `json:"thanos,omitempty"`
 }
 
 type ImageSpec struct {
@@ -55,6 +262,381 @@ type ImageSpec struct {
 
 	// Version of Prometheus
 	Version string `json:"version"`
+
+	// Digest of the image, pinning it in place of the Version tag. Version must
+	// still match the image as it drives the version dependent rendering.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^sha256:[a-f0-9]{64}$`
+	Digest string `json:"digest,omitempty"`
+}
+
+const (
+	// ConfigReloaderSidecar reloads Prometheus from a configmap-reload sidecar container.
+	ConfigReloaderSidecar = "sidecar"
+	// ConfigReloaderOperator reloads Prometheus from the operator, calling /-/reload on each replica.
+	ConfigReloaderOperator = "operator"
+)
+
+const (
+	// ModeServer runs Prometheus as a server storing the samples in its TSDB.
+	ModeServer = "server"
+	// ModeAgent runs Prometheus as an agent forwarding the samples to remote write.
+	ModeAgent = "agent"
+)
+
+// RemoteWriteSpec defines a remote write endpoint
+type RemoteWriteSpec struct {
+
+	// URL of the endpoint
+	URL string `json:"url"`
+
+	// Name of the remote write queue
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// RemoteTimeout of requests to the endpoint
+	// +optional
+	RemoteTimeout string `json:"remoteTimeout,omitempty"`
+
+	// +optional
+	TlsConfig *TLSConfig `json:"tlsConfig,omitempty"`
+	// +optional
+	BearerTokenFile string `json:"bearerTokenFile,omitempty"`
+}
+
+const (
+	// ShardRetentionRetain keeps the PersistentVolumeClaims of removed shards.
+	ShardRetentionRetain = "Retain"
+	// ShardRetentionDelete deletes the PersistentVolumeClaims of removed shards.
+	ShardRetentionDelete = "Delete"
+)
+
+// ConfigReloaderSpec defines the reload of Prometheus on configuration changes
+type ConfigReloaderSpec struct {
+
+	// Strategy of the reload, either a "sidecar" container watching the mounted ConfigMaps,
+	// or the "operator" calling /-/reload on each replica once the ConfigMaps changed.
+	// The operator strategy is always used when the web server is served over TLS.
+	// +optional
+	// +kubebuilder:validation:Enum=sidecar;operator
+	// +kubebuilder:default=sidecar
+	Strategy string `json:"strategy,omitempty"`
+
+	// Repository of the reload sidecar image
+	// +optional
+	// +kubebuilder:default=jimmidyson/configmap-reload
+	Repository string `json:"repository,omitempty"`
+
+	// Version of the reload sidecar image
+	// +optional
+	// +kubebuilder:default=v0.6.1
+	Version string `json:"version,omitempty"`
+
+	// Digest of the reload sidecar image, pinning it in place of the Version tag.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^sha256:[a-f0-9]{64}$`
+	Digest string `json:"digest,omitempty"`
+
+	// ImagePullPolicy of the reload sidecar image
+	// +optional
+	// +kubebuilder:validation:Enum=Always;Never;IfNotPresent
+	// +kubebuilder:default=IfNotPresent
+	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy,omitempty"`
+
+	// Compute Resources for the reload sidecar.
+	// +optional
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// Args additional arguments of the reload sidecar
+	// +optional
+	Args []string `json:"args,omitempty"`
+}
+
+// WebSpec defines the web server of Prometheus
+type WebSpec struct {
+
+	// TLS serves the UI and API over HTTPS. The reload sidecar cannot verify the certificate on
+	// localhost, so the operator reload strategy is used. Requires Prometheus v2.24.0 or later.
+	// +optional
+	TLS *WebTLSSpec `json:"tls,omitempty"`
+
+	// BasicAuthUsers Secret mapping user names to their bcrypt hashed password.
+	// Requires Prometheus v2.24.0 or later.
+	// +optional
+	BasicAuthUsers *corev1.LocalObjectReference `json:"basicAuthUsers,omitempty"`
+
+	// RoutePrefix of the web endpoints, defaults to the path of ExternalURL
+	// +optional
+	RoutePrefix string `json:"routePrefix,omitempty"`
+
+	// ExternalURL Prometheus is reachable at, used in generated links
+	// +optional
+	ExternalURL string `json:"externalURL,omitempty"`
+
+	// PageTitle of the web UI
+	// +optional
+	PageTitle string `json:"pageTitle,omitempty"`
+}
+
+// ServiceSpec defines the client-facing Service of Prometheus
+type ServiceSpec struct {
+
+	// Type of the Service
+	// +optional
+	// +kubebuilder:validation:Enum=ClusterIP;NodePort;LoadBalancer
+	// +kubebuilder:default=ClusterIP
+	Type corev1.ServiceType `json:"type,omitempty"`
+
+	// Annotations of the Service
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Labels of the Service, added to the labels set by the operator.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// LoadBalancerSourceRanges restricts the clients of a LoadBalancer Service
+	// +optional
+	LoadBalancerSourceRanges []string `json:"loadBalancerSourceRanges,omitempty"`
+
+	// Ports additional ports of the Service, next to the "http" port of Prometheus.
+	// +optional
+	Ports []corev1.ServicePort `json:"ports,omitempty"`
+
+	// SessionAffinity of the Service
+	// +optional
+	// +kubebuilder:validation:Enum=ClientIP;None
+	// +kubebuilder:default=ClientIP
+	SessionAffinity corev1.ServiceAffinity `json:"sessionAffinity,omitempty"`
+}
+
+// NetworkPolicySpec defines the NetworkPolicy of the Prometheus pods
+type NetworkPolicySpec struct {
+
+	// From peers allowed to reach the web port of Prometheus, and the Thanos ports when enabled.
+	// The operator pods of the operator namespace are always allowed.
+	// +optional
+	From []networkingv1.NetworkPolicyPeer `json:"from,omitempty"`
+
+	// EgressNamespaces allowed to be reached next to the namespaces derived from the targets,
+	// whose hosts name a namespace as in <service>.<namespace> or <service>.<namespace>.svc.
+	// Required by the jobs discovering Kubernetes targets in any namespace.
+	// +optional
+	EgressNamespaces []string `json:"egressNamespaces,omitempty"`
+
+	// EgressCIDRs allowed to be reached, for targets, remote write endpoints or object storage
+	// outside of the cluster. Required by the jobs discovering the nodes.
+	// +optional
+	EgressCIDRs []string `json:"egressCIDRs,omitempty"`
+}
+
+// PresetsSpec defines the jobs monitoring the components of the cluster. Each preset adds a job
+// named after it, discovering its targets through the Kubernetes API. With a NetworkPolicy, the
+// kubelet and cadvisor presets require EgressCIDRs covering the nodes, and kube-state-metrics
+// EgressNamespaces listing its namespace.
+type PresetsSpec struct {
+
+	// Kubelet adds the "kubelet" job scraping the kubelet of every node
+	// +optional
+	Kubelet bool `json:"kubelet,omitempty"`
+
+	// Cadvisor adds the "cadvisor" job scraping the container metrics of every kubelet
+	// +optional
+	Cadvisor bool `json:"cadvisor,omitempty"`
+
+	// APIServer adds the "apiserver" job scraping the Kubernetes API servers
+	// +optional
+	APIServer bool `json:"apiServer,omitempty"`
+
+	// KubeStateMetrics adds the "kube-state-metrics" job scraping the endpoints of the Services
+	// labelled app.kubernetes.io/name=kube-state-metrics
+	// +optional
+	KubeStateMetrics bool `json:"kubeStateMetrics,omitempty"`
+
+	// CoreDNS adds the "coredns" job scraping the endpoints of the kube-dns Service of kube-system
+	// +optional
+	CoreDNS bool `json:"coreDNS,omitempty"`
+}
+
+// TargetHealthSpec defines the polling of the target health
+type TargetHealthSpec struct {
+
+	// Interval between two polls of the targets of the replicas
+	// +optional
+	// +kubebuilder:default="1m"
+	Interval metav1.Duration `json:"interval,omitempty"`
+}
+
+// IngressSpec defines the Ingress of Prometheus
+type IngressSpec struct {
+
+	// ClassName of the IngressClass handling the Ingress
+	// +optional
+	ClassName *string `json:"className,omitempty"`
+
+	// Host Prometheus is reachable at
+	Host string `json:"host"`
+
+	// Path Prometheus is reachable at
+	// +optional
+	// +kubebuilder:default=/
+	Path string `json:"path,omitempty"`
+
+	// TLSSecretName of the Secret holding the certificate of Host, serving the Ingress over HTTPS.
+	// +optional
+	TLSSecretName string `json:"tlsSecretName,omitempty"`
+
+	// Annotations of the Ingress
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// HTTPRouteSpec defines the Gateway API HTTPRoute of Prometheus
+type HTTPRouteSpec struct {
+
+	// ParentRefs Gateways the HTTPRoute attaches to
+	// +kubebuilder:validation:MinItems=1
+	ParentRefs []GatewayReference `json:"parentRefs"`
+
+	// Hostnames Prometheus is reachable at, the first one is used in the external URL.
+	// +kubebuilder:validation:MinItems=1
+	Hostnames []string `json:"hostnames"`
+
+	// Path Prometheus is reachable at
+	// +optional
+	// +kubebuilder:default=/
+	Path string `json:"path,omitempty"`
+
+	// Scheme of the Gateway listener, used in the external URL
+	// +optional
+	// +kubebuilder:validation:Enum=http;https
+	// +kubebuilder:default=https
+	Scheme string `json:"scheme,omitempty"`
+
+	// Annotations of the HTTPRoute
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// GatewayReference references a Gateway
+type GatewayReference struct {
+
+	// Name of the Gateway
+	Name string `json:"name"`
+
+	// Namespace of the Gateway, defaults to the namespace of the Prometheus.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// SectionName of the Gateway listener
+	// +optional
+	SectionName string `json:"sectionName,omitempty"`
+}
+
+// WebTLSSpec defines the TLS configuration of the Prometheus web server
+type WebTLSSpec struct {
+
+	// Cert Secret key holding the PEM encoded server certificate
+	Cert corev1.SecretKeySelector `json:"cert"`
+
+	// Key Secret key holding the PEM encoded server private key
+	Key corev1.SecretKeySelector `json:"key"`
+
+	// ClientCA Secret key holding the PEM encoded CA verifying client certificates
+	// +optional
+	ClientCA *corev1.SecretKeySelector `json:"clientCA,omitempty"`
+
+	// ClientAuthType policy for client certificates, defaults to VerifyClientCertIfGiven
+	// when ClientCA is set and NoClientCert otherwise.
+	// +optional
+	// +kubebuilder:validation:Enum=NoClientCert;RequestClientCert;RequireAnyClientCert;VerifyClientCertIfGiven;RequireAndVerifyClientCert
+	ClientAuthType string `json:"clientAuthType,omitempty"`
+
+	// CA Secret key holding the PEM encoded CA issuing the server certificate. The operator and
+	// the self-scrape job verify the replicas against it. Required with BasicAuthUsers, the
+	// reloader credentials are only sent to verified replicas.
+	// +optional
+	CA *corev1.SecretKeySelector `json:"ca,omitempty"`
+
+	// ServerName the server certificate is verified for, the replicas being reached by pod IP.
+	// Defaults to the DNS name of the Service, <name>.<namespace>.svc.
+	// +optional
+	ServerName string `json:"serverName,omitempty"`
+}
+
+// ThanosSpec defines the Thanos sidecar. It queries Prometheus over plain HTTP without
+// credentials, so it cannot be combined with Web.TLS or Web.BasicAuthUsers.
+type ThanosSpec struct {
+
+	// Repository of the Thanos image
+	// +optional
+	// +kubebuilder:default=quay.io/thanos/thanos
+	Repository string `json:"repository,omitempty"`
+
+	// Version of Thanos
+	// +optional
+	// +kubebuilder:default=v0.25.2
+	Version string `json:"version,omitempty"`
+
+	// Digest of the Thanos image, pinning it in place of the Version tag.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^sha256:[a-f0-9]{64}$`
+	Digest string `json:"digest,omitempty"`
+
+	// ImagePullPolicy of the Thanos image
+	// +optional
+	// +kubebuilder:validation:Enum=Always;Never;IfNotPresent
+	// +kubebuilder:default=IfNotPresent
+	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy,omitempty"`
+
+	// ObjectStorageConfig Secret key holding the Thanos object storage configuration.
+	// Blocks are not uploaded when not set.
+	// +optional
+	ObjectStorageConfig *corev1.SecretKeySelector `json:"objectStorageConfig,omitempty"`
+
+	// GRPCPort port of the StoreAPI
+	// +optional
+	// +kubebuilder:default=10901
+	GRPCPort int32 `json:"grpcPort,omitempty"`
+
+	// HTTPPort port of the Thanos sidecar metrics and probes
+	// +optional
+	// +kubebuilder:default=10902
+	HTTPPort int32 `json:"httpPort,omitempty"`
+
+	// Compute Resources for the Thanos sidecar.
+	// +optional
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+// PodDisruptionBudgetSpec defines the PodDisruptionBudget of the Prometheus replicas, one per
+// shard counting the replicas of the shard.
+type PodDisruptionBudgetSpec struct {
+
+	// Enabled creates the PodDisruptionBudget regardless of the number of replicas when true,
+	// and never creates it when false.
+	// +optional
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// MaxUnavailable replicas during a disruption, defaults to 1.
+	// +optional
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+
+	// MinAvailable replicas during a disruption, mutually exclusive with MaxUnavailable.
+	// +optional
+	MinAvailable *intstr.IntOrString `json:"minAvailable,omitempty"`
+}
+
+// FileSDConfigMap defines a ConfigMap of file_sd target groups
+type FileSDConfigMap struct {
+
+	// Name of the ConfigMap
+	Name string `json:"name"`
+
+	// JobName of the job scraping the targets, the name of the ConfigMap by default
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9_.-]+$`
+	JobName string `json:"jobName,omitempty"`
 }
 
 // Prometheus defines the spec of Prometheus targets
@@ -62,6 +644,36 @@ type PrometheusTarget struct {
 	Targets []string `json:"targets,omitempty"`
 
 	Labels map[string]string `json:"labels,omitempty"`
+
+	// JobName of the file_sd job scraping the group. Groups without job name are scraped
+	// by the legacy "gs" job. The scrape options set by the groups of a job apply to the job,
+	// and groups of a job cannot set an option to different values.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9_.-]+$`
+	JobName string `json:"jobName,omitempty"`
+
+	// ScrapeInterval of the job
+	// +optional
+	ScrapeInterval string `json:"scrapeInterval,omitempty"`
+
+	// ScrapeTimeout of the job
+	// +optional
+	ScrapeTimeout string `json:"scrapeTimeout,omitempty"`
+
+	// Scheme of the job
+	// +optional
+	// +kubebuilder:validation:Enum=http;https
+	Scheme string `json:"scheme,omitempty"`
+
+	// MetricsPath of the job
+	// +optional
+	MetricsPath string `json:"metricsPath,omitempty"`
+
+	// +optional
+	TlsConfig *TLSConfig `json:"tlsConfig,omitempty"`
+
+	// +optional
+	BearerTokenFile string `json:"bearerTokenFile,omitempty"`
 }
 
 // ScrapeConfig
@@ -75,13 +687,220 @@ type ScrapeConfig struct {
 	// +optional
 	BearerTokenFile string `json:"bearerTokenFile,omitempty"`
 
-	StaticConfigs []StaticConfig `json:"staticConfigs"`
+	// KeepDroppedTargets limit of targets dropped by relabeling kept in memory,
+	// 0 meaning no limit. Requires Prometheus v2.47.0 or later.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	KeepDroppedTargets *int32 `json:"keepDroppedTargets,omitempty"`
+
+	// +optional
+	StaticConfigs []StaticConfig `json:"staticConfigs,omitempty"`
+
+	// KubernetesSDConfigs discover the targets from the Kubernetes API, authenticating with the
+	// service account of Prometheus. Its ClusterRole is extended with the resources of the roles.
+	// +optional
+	KubernetesSDConfigs []KubernetesSDConfig `json:"kubernetesSDConfigs,omitempty"`
+
+	// DNSSDConfigs discover the targets from DNS records.
+	// +optional
+	DNSSDConfigs []DNSSDConfig `json:"dnsSDConfigs,omitempty"`
+
+	// HTTPSDConfigs discover the targets from HTTP endpoints. Requires Prometheus v2.21.0 or later.
+	// +optional
+	HTTPSDConfigs []HTTPSDConfig `json:"httpSDConfigs,omitempty"`
+
+	// ConsulSDConfigs discover the targets from the Consul catalog.
+	// +optional
+	ConsulSDConfigs []ConsulSDConfig `json:"consulSDConfigs,omitempty"`
+
+	// Federate scrapes the /federate endpoint of another Prometheus of the operator through its
+	// Service, honoring the labels of the federated series. It excludes any other target. The
+	// NetworkPolicy of the federated Prometheus must allow this one through its from peers.
+	// Agents, sharded Prometheuses and Prometheuses served over TLS or requiring basic auth
+	// cannot be federated.
+	// +optional
+	Federate *FederationSpec `json:"federate,omitempty"`
+}
+
+// FederationSpec defines the series federated from another Prometheus
+type FederationSpec struct {
+
+	// Prometheus federated
+	Prometheus PrometheusReference `json:"prometheus"`
+
+	// Match selectors of the federated series
+	// +kubebuilder:validation:MinItems=1
+	Match []string `json:"match"`
+}
+
+// PrometheusReference references a Prometheus
+type PrometheusReference struct {
+
+	// Namespace of the Prometheus, the namespace of the referencing object by default
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Name of the Prometheus
+	Name string `json:"name"`
 }
 
 type StaticConfig struct {
 	Targets []string `json:"targets"`
 }
 
+// DNSSDConfig defines the discovery of targets from DNS records
+type DNSSDConfig struct {
+
+	// Names of the records to query
+	// +kubebuilder:validation:MinItems=1
+	Names []string `json:"names"`
+
+	// Type of the records
+	// +optional
+	// +kubebuilder:default=SRV
+	// +kubebuilder:validation:Enum=SRV;A;AAAA
+	Type string `json:"type,omitempty"`
+
+	// Port of the targets, required for A and AAAA records
+	// +optional
+	Port int32 `json:"port,omitempty"`
+
+	// RefreshInterval of the records, 30s by default
+	// +optional
+	RefreshInterval string `json:"refreshInterval,omitempty"`
+}
+
+// HTTPSDConfig defines the discovery of targets from an HTTP endpoint serving target groups
+type HTTPSDConfig struct {
+
+	// URL of the endpoint
+	URL string `json:"url"`
+
+	// RefreshInterval of the target groups, 60s by default
+	// +optional
+	RefreshInterval string `json:"refreshInterval,omitempty"`
+
+	// BasicAuth authenticating to the endpoint
+	// +optional
+	BasicAuth *BasicAuth `json:"basicAuth,omitempty"`
+
+	// BearerToken Secret key holding the token authenticating to the endpoint
+	// +optional
+	BearerToken *corev1.SecretKeySelector `json:"bearerToken,omitempty"`
+
+	// +optional
+	TlsConfig *TLSConfig `json:"tlsConfig,omitempty"`
+}
+
+// BasicAuth defines the basic authentication to an endpoint, the password being read from a Secret
+type BasicAuth struct {
+
+	// Username to authenticate as
+	Username string `json:"username"`
+
+	// Password Secret key holding the password
+	Password corev1.SecretKeySelector `json:"password"`
+}
+
+// ConsulSDConfig defines the discovery of targets from the Consul catalog
+type ConsulSDConfig struct {
+
+	// Server address of the Consul agent, localhost:8500 by default
+	// +optional
+	Server string `json:"server,omitempty"`
+
+	// Scheme of the Consul API
+	// +optional
+	// +kubebuilder:validation:Enum=http;https
+	Scheme string `json:"scheme,omitempty"`
+
+	// Datacenter to query, the one of the agent by default
+	// +optional
+	Datacenter string `json:"datacenter,omitempty"`
+
+	// Services to discover, all services when empty
+	// +optional
+	Services []string `json:"services,omitempty"`
+
+	// Tags the discovered nodes must have
+	// +optional
+	Tags []string `json:"tags,omitempty"`
+
+	// NodeMeta the discovered nodes must have
+	// +optional
+	NodeMeta map[string]string `json:"nodeMeta,omitempty"`
+
+	// AllowStale allows reads from any Consul server, not only the leader
+	// +optional
+	AllowStale *bool `json:"allowStale,omitempty"`
+
+	// RefreshInterval of the catalog, 30s by default
+	// +optional
+	RefreshInterval string `json:"refreshInterval,omitempty"`
+
+	// +optional
+	TlsConfig *TLSConfig `json:"tlsConfig,omitempty"`
+}
+
+// KubernetesRole kind of Kubernetes objects discovered as targets
+// +kubebuilder:validation:Enum=node;service;pod;endpoints;endpointslice;ingress
+type KubernetesRole string
+
+const (
+	KubernetesRoleNode          KubernetesRole = "node"
+	KubernetesRoleService       KubernetesRole = "service"
+	KubernetesRolePod           KubernetesRole = "pod"
+	KubernetesRoleEndpoints     KubernetesRole = "endpoints"
+	KubernetesRoleEndpointSlice KubernetesRole = "endpointslice"
+	KubernetesRoleIngress       KubernetesRole = "ingress"
+)
+
+// KubernetesSDConfig defines the discovery of targets from the Kubernetes API
+type KubernetesSDConfig struct {
+
+	// Role of the discovered objects. The endpointslice role requires Prometheus v2.21.0 or later.
+	Role KubernetesRole `json:"role"`
+
+	// Namespaces to discover the objects in, all namespaces when empty. The NetworkPolicy allows
+	// these namespaces, the targets of any namespace require NetworkPolicy EgressNamespaces and
+	// the nodes NetworkPolicy EgressCIDRs.
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// Selectors restricting the discovered objects on the API server side.
+	// +optional
+	Selectors []KubernetesSelector `json:"selectors,omitempty"`
+
+	// AttachMetadata adds the labels of the node of the targets, for the pod, endpoints and
+	// endpointslice roles. Requires Prometheus v2.35.0 or later, v2.37.0 for the endpoints and
+	// endpointslice roles.
+	// +optional
+	AttachMetadata *AttachMetadata `json:"attachMetadata,omitempty"`
+}
+
+// KubernetesSelector defines a label and field selector of the objects of a role
+type KubernetesSelector struct {
+
+	// Role of the objects the selector applies to
+	Role KubernetesRole `json:"role"`
+
+	// Label selector, for example "app=prometheus"
+	// +optional
+	Label string `json:"label,omitempty"`
+
+	// Field selector, for example "status.phase=Running"
+	// +optional
+	Field string `json:"field,omitempty"`
+}
+
+// AttachMetadata defines the metadata attached to the discovered targets
+type AttachMetadata struct {
+
+	// Node attaches the labels of the node of the targets
+	// +optional
+	Node bool `json:"node,omitempty"`
+}
+
 type TLSConfig struct {
 	// +kubebuilder:default=true
 	InsecureSkipVerify bool `json:"insecureSkipVerify"`
@@ -92,6 +911,133 @@ type PrometheusStatus struct {
 
 	// ReadyReplicas number of ready replicas
 	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+
+	// Replicas images currently running on each replica
+	// +optional
+	Replicas []ReplicaStatus `json:"replicas,omitempty"`
+
+	// RetainedVolumeClaims PersistentVolumeClaims of removed shards kept by the Retain ShardRetentionPolicy
+	// +optional
+	RetainedVolumeClaims []string `json:"retainedVolumeClaims,omitempty"`
+
+	// DisruptionsAllowed number of replicas the PodDisruptionBudgets currently let be evicted
+	// in the most constrained shard, zero means disruptions are blocked. Not set when there is
+	// no PodDisruptionBudget.
+	// +optional
+	DisruptionsAllowed *int32 `json:"disruptionsAllowed,omitempty"`
+
+	// ConfigReload state of the reloads done by the operator reload strategy.
+	// +optional
+	ConfigReload *ConfigReloadStatus `json:"configReload,omitempty"`
+
+	// Federation endpoints of the federation jobs.
+	// +optional
+	Federation []FederationStatus `json:"federation,omitempty"`
+
+	// TargetHealth of the targets, summarized per job.
+	// +optional
+	TargetHealth *TargetHealthStatus `json:"targetHealth,omitempty"`
+
+	// Conditions of the Prometheus
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+const (
+	// ConditionValid reports whether the spec can be rendered for the Prometheus version.
+	ConditionValid = "Valid"
+)
+
+// FederationStatus defines the endpoint a federation job scrapes
+type FederationStatus struct {
+
+	// JobName of the federation job
+	JobName string `json:"jobName"`
+
+	// Prometheus federated, as namespace/name
+	Prometheus string `json:"prometheus"`
+
+	// Endpoint URL of the /federate endpoint, empty when it cannot be resolved
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// Message why the endpoint cannot be resolved
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// TargetHealthStatus defines the observed health of the targets
+type TargetHealthStatus struct {
+
+	// CheckTime of the last poll of the replicas
+	CheckTime metav1.Time `json:"checkTime"`
+
+	// Jobs health of the targets of each job
+	// +optional
+	Jobs []JobHealth `json:"jobs,omitempty"`
+}
+
+// JobHealth defines the observed health of the targets of a job
+type JobHealth struct {
+
+	// Job name
+	Job string `json:"job"`
+
+	TargetHealth `json:",inline"`
+}
+
+// TargetHealth defines the observed health of a set of targets. A target scraped by several
+// replicas is only up when it is up on all of them.
+type TargetHealth struct {
+
+	// Active number of targets
+	Active int32 `json:"active"`
+
+	// Up number of targets
+	Up int32 `json:"up"`
+
+	// Down number of targets
+	Down int32 `json:"down"`
+
+	// LastError sample of the scrape error of a down target
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+}
+
+// ReplicaStatus defines the observed state of a Prometheus replica
+type ReplicaStatus struct {
+
+	// Name of the pod
+	Name string `json:"name"`
+
+	// Image reference of the running prometheus container
+	Image string `json:"image,omitempty"`
+
+	// ImageID exact image, including its digest, of the running prometheus container
+	ImageID string `json:"imageID,omitempty"`
+
+	// ThanosImage reference of the running Thanos sidecar container
+	// +optional
+	ThanosImage string `json:"thanosImage,omitempty"`
+
+	// ThanosImageID exact image, including its digest, of the running Thanos sidecar container
+	// +optional
+	ThanosImageID string `json:"thanosImageID,omitempty"`
+}
+
+// ConfigReloadStatus defines the observed state of the operator reload strategy
+type ConfigReloadStatus struct {
+
+	// ConfigHash hash of the current Prometheus configuration and targets
+	ConfigHash string `json:"configHash"`
+
+	// ChangeTime time the configuration change was detected
+	ChangeTime metav1.Time `json:"changeTime"`
+
+	// Reloaded whether all replicas reloaded the current configuration
+	Reloaded bool `json:"reloaded"`
 }
 
 //+kubebuilder:object:root=true
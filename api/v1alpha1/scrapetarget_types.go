@@ -0,0 +1,80 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ScrapeTargetSpec defines the desired state of ScrapeTarget
+type ScrapeTargetSpec struct {
+
+	// TargetGroups scraped by the Prometheuses selecting the ScrapeTarget, next to their own Targets.
+	TargetGroups []PrometheusTarget `json:"targetGroups"`
+}
+
+// ScrapeTargetStatus defines the observed state of ScrapeTarget
+type ScrapeTargetStatus struct {
+
+	// Prometheuses which picked up the target groups
+	// +optional
+	Prometheuses []ScrapeTargetPrometheusStatus `json:"prometheuses,omitempty"`
+}
+
+// ScrapeTargetPrometheusStatus defines how a Prometheus picked up a ScrapeTarget
+type ScrapeTargetPrometheusStatus struct {
+
+	// Namespace of the Prometheus
+	Namespace string `json:"namespace"`
+
+	// Name of the Prometheus
+	Name string `json:"name"`
+
+	// Conflicts targets left out as the Prometheus already scrapes them through another target group.
+	// +optional
+	Conflicts []string `json:"conflicts,omitempty"`
+
+	// Health of the targets of the ScrapeTarget, when the Prometheus polls the target health.
+	// +optional
+	Health *TargetHealth `json:"health,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Time duration since creation of ScrapeTarget"
+
+// ScrapeTarget is the Schema for the scrapetargets API
+type ScrapeTarget struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ScrapeTargetSpec   `json:"spec,omitempty"`
+	Status ScrapeTargetStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ScrapeTargetList contains a list of ScrapeTarget
+type ScrapeTargetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ScrapeTarget `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ScrapeTarget{}, &ScrapeTargetList{})
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	prometheus "github.com/mcbenjemaa/gs-prometheus-operator/internal/prometheus"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crlog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+)
+
+const (
+	// reloadDelay leaves the kubelet time to propagate ConfigMap changes to the mounted volumes
+	reloadDelay   = 90 * time.Second
+	reloadTimeout = 10 * time.Second
+)
+
+// reconcileConfigReload reloads the Prometheus replicas once a configuration change
+// reached their volumes, when the operator reload strategy is used.
+func (r *PrometheusReconciler) reconcileConfigReload(ctx context.Context, p *monitoringv1alpha1.Prometheus, tg prometheus.TargetGroups) (ctrl.Result, error) {
+	log := crlog.FromContext(ctx)
+
+	if prometheus.ReloadStrategy(p) != monitoringv1alpha1.ConfigReloaderOperator {
+		if p.Status.ConfigReload != nil {
+			p.Status.ConfigReload = nil
+			return ctrl.Result{}, r.Status().Update(ctx, p)
+		}
+		return ctrl.Result{}, nil
+	}
+
+	hash, err := prometheus.ConfigHash(p, tg)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Record the configuration change
+	if p.Status.ConfigReload == nil || p.Status.ConfigReload.ConfigHash != hash {
+		p.Status.ConfigReload = &monitoringv1alpha1.ConfigReloadStatus{
+			ConfigHash: hash,
+			ChangeTime: metav1.Now(),
+		}
+		if err := r.Status().Update(ctx, p); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: reloadDelay}, nil
+	}
+	if p.Status.ConfigReload.Reloaded {
+		return ctrl.Result{}, nil
+	}
+	if wait := reloadDelay - time.Since(p.Status.ConfigReload.ChangeTime.Time); wait > 0 {
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+
+	password, err := r.reloaderPassword(ctx, p)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	httpClient, verified, err := r.replicaClient(ctx, p)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if password != "" && !verified {
+		return ctrl.Result{}, fmt.Errorf("refusing to send the reloader credentials to unverified replicas")
+	}
+
+	// Reload every running replica
+	var pods core.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(p.Namespace), client.MatchingLabels(prometheus.PodLabels(p))); err != nil {
+		return ctrl.Result{}, err
+	}
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != core.PodRunning || pod.Status.PodIP == "" {
+			continue
+		}
+		if err := r.reloadPod(ctx, httpClient, p, &pod, password); err != nil {
+			// Retry later, the replica may still be starting
+			return ctrl.Result{RequeueAfter: reloadDelay}, err
+		}
+	}
+	log.Info("Prometheus configuration reloaded")
+	r.recorder.Eventf(p, core.EventTypeNormal, "PrometheusConfigReloaded", "Prometheus %v configuration is reloaded", p.Name)
+
+	p.Status.ConfigReload.Reloaded = true
+	return ctrl.Result{}, r.Status().Update(ctx, p)
+}
+
+func (r *PrometheusReconciler) reloadPod(ctx context.Context, httpClient *http.Client, p *monitoringv1alpha1.Prometheus, pod *core.Pod, password string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, prometheus.ReloadURL(p, pod.Status.PodIP), nil)
+	if err != nil {
+		return err
+	}
+	if password != "" {
+		req.SetBasicAuth(prometheus.ReloaderUsername, password)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to reload pod %v: %v", pod.Name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unable to reload pod %v: %v", pod.Name, resp.Status)
+	}
+	return nil
+}
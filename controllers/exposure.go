@@ -0,0 +1,134 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/google/go-cmp/cmp"
+	prometheus "github.com/mcbenjemaa/gs-prometheus-operator/internal/prometheus"
+	core "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrltypes "k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	crlog "sigs.k8s.io/controller-runtime/pkg/log"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+)
+
+// reconcileIngress reconciles the Ingress exposing the Prometheus
+func (r *PrometheusReconciler) reconcileIngress(ctx context.Context, p *monitoringv1alpha1.Prometheus) error {
+	log := crlog.FromContext(ctx)
+
+	// Retrieve Ingress
+	var ingress networkingv1.Ingress
+	nn := ctrltypes.NamespacedName{Namespace: p.ObjectMeta.Namespace, Name: p.ObjectMeta.Name}
+	err := r.Get(ctx, nn, &ingress)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	exists := err == nil
+
+	desiredIngress, needed := prometheus.DesiredIngress(p)
+	switch {
+	case exists && !metav1.IsControlledBy(&ingress, p):
+		// Leave alone an Ingress the Prometheus does not own
+		if needed {
+			r.reportNotOwned(p, "Ingress", nn.Name)
+		}
+	case !needed && exists:
+		// Delete Ingress
+		log.Info("Delete Ingress")
+		if err := r.Delete(ctx, &ingress); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	case needed && !exists:
+		// Create Ingress
+		if err := ctrl.SetControllerReference(p, &desiredIngress, r.Scheme); err != nil {
+			return err
+		}
+		if err := r.Create(ctx, &desiredIngress); err != nil {
+			return err
+		}
+		r.recorder.Eventf(p, core.EventTypeNormal, "IngressCreated", "Ingress %v is created", nn.Name)
+	case needed && exists:
+		// Check Diff & Update Ingress
+		if !cmp.Equal(ingress.Spec, desiredIngress.Spec) || !cmp.Equal(ingress.Annotations, desiredIngress.Annotations) {
+			log.Info("Update Ingress")
+			ingress.Spec = desiredIngress.Spec
+			ingress.Annotations = desiredIngress.Annotations
+			return r.Update(ctx, &ingress)
+		}
+	}
+	return nil
+}
+
+// reconcileHTTPRoute reconciles the Gateway API HTTPRoute exposing the Prometheus
+func (r *PrometheusReconciler) reconcileHTTPRoute(ctx context.Context, p *monitoringv1alpha1.Prometheus) error {
+	log := crlog.FromContext(ctx)
+
+	desiredRoute, needed := prometheus.DesiredHTTPRoute(p)
+
+	// Retrieve HTTPRoute
+	var route gatewayv1alpha2.HTTPRoute
+	nn := ctrltypes.NamespacedName{Namespace: p.ObjectMeta.Namespace, Name: p.ObjectMeta.Name}
+	err := r.Get(ctx, nn, &route)
+	if meta.IsNoMatchError(err) && !needed {
+		// Gateway API is not installed, so there is no HTTPRoute to clean up
+		return nil
+	}
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	exists := err == nil
+
+	switch {
+	case exists && !metav1.IsControlledBy(&route, p):
+		// Leave alone an HTTPRoute the Prometheus does not own
+		if needed {
+			r.reportNotOwned(p, "HTTPRoute", nn.Name)
+		}
+	case !needed && exists:
+		// Delete HTTPRoute
+		log.Info("Delete HTTPRoute")
+		if err := r.Delete(ctx, &route); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	case needed && !exists:
+		// Create HTTPRoute
+		if err := ctrl.SetControllerReference(p, &desiredRoute, r.Scheme); err != nil {
+			return err
+		}
+		if err := r.Create(ctx, &desiredRoute); err != nil {
+			return err
+		}
+		r.recorder.Eventf(p, core.EventTypeNormal, "HTTPRouteCreated", "HTTPRoute %v is created", nn.Name)
+	case needed && exists:
+		// Check Diff & Update HTTPRoute
+		if !cmp.Equal(route.Spec, desiredRoute.Spec) || !cmp.Equal(route.Annotations, desiredRoute.Annotations) {
+			log.Info("Update HTTPRoute")
+			route.Spec = desiredRoute.Spec
+			route.Annotations = desiredRoute.Annotations
+			return r.Update(ctx, &route)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/google/go-cmp/cmp"
+	prometheus "github.com/mcbenjemaa/gs-prometheus-operator/internal/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrltypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crlog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+)
+
+// resolveFederation resolves the endpoints of the federation jobs of the Prometheus
+func (r *PrometheusReconciler) resolveFederation(ctx context.Context, p *monitoringv1alpha1.Prometheus, tg *prometheus.TargetGroups) error {
+	for _, sc := range p.Spec.AdditionalScrapeConfig {
+		if sc.Federate == nil {
+			continue
+		}
+		var federated monitoringv1alpha1.Prometheus
+		ref := &federated
+		if err := r.Get(ctx, prometheus.FederatedPrometheus(p, sc), &federated); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return err
+			}
+			ref = nil
+		}
+		tg.Federation = append(tg.Federation, prometheus.ResolveFederation(p, sc, ref))
+	}
+	return nil
+}
+
+// reconcileFederationStatus records the endpoints of the federation jobs in the status
+func (r *PrometheusReconciler) reconcileFederationStatus(ctx context.Context, p *monitoringv1alpha1.Prometheus, tg prometheus.TargetGroups) error {
+	log := crlog.FromContext(ctx)
+
+	if cmp.Equal(p.Status.Federation, tg.Federation) {
+		return nil
+	}
+	log.Info("Update federation status")
+	p.Status.Federation = tg.Federation
+	return r.Status().Update(ctx, p)
+}
+
+// prometheusesForFederatedPrometheus maps a Prometheus to the Prometheuses federating it
+func (r *PrometheusReconciler) prometheusesForFederatedPrometheus(obj client.Object) []reconcile.Request {
+	var list monitoringv1alpha1.PrometheusList
+	if err := r.List(context.Background(), &list); err != nil {
+		return nil
+	}
+
+	nn := ctrltypes.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}
+	var requests []reconcile.Request
+	for i := range list.Items {
+		p := &list.Items[i]
+		if prometheus.Federates(p, nn) {
+			requests = append(requests, reconcile.Request{NamespacedName: ctrltypes.NamespacedName{Namespace: p.Namespace, Name: p.Name}})
+		}
+	}
+	return requests
+}
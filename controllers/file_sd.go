@@ -0,0 +1,69 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	prometheus "github.com/mcbenjemaa/gs-prometheus-operator/internal/prometheus"
+	core "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrltypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+)
+
+// fileSDKeys returns the keys of each FileSDConfigMap holding valid target groups, and the
+// validation errors of the other keys. Missing ConfigMaps have no keys.
+func (r *PrometheusReconciler) fileSDKeys(ctx context.Context, p *monitoringv1alpha1.Prometheus) (map[string][]string, []error, error) {
+	keys := make(map[string][]string, len(p.Spec.FileSDConfigMaps))
+	var invalid []error
+	for _, ref := range p.Spec.FileSDConfigMaps {
+		var cm core.ConfigMap
+		if err := r.Get(ctx, ctrltypes.NamespacedName{Namespace: p.Namespace, Name: ref.Name}, &cm); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, nil, err
+		}
+		valid, errs := prometheus.FileSDKeys(cm)
+		keys[ref.Name] = valid
+		invalid = append(invalid, errs...)
+	}
+	return keys, invalid, nil
+}
+
+// prometheusesForFileSDConfigMap maps a ConfigMap to the Prometheuses of its namespace reading it as file_sd
+func (r *PrometheusReconciler) prometheusesForFileSDConfigMap(obj client.Object) []reconcile.Request {
+	var list monitoringv1alpha1.PrometheusList
+	if err := r.List(context.Background(), &list, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, p := range list.Items {
+		for _, ref := range p.Spec.FileSDConfigMaps {
+			if ref.Name == obj.GetName() {
+				requests = append(requests, reconcile.Request{NamespacedName: ctrltypes.NamespacedName{Namespace: p.Namespace, Name: p.Name}})
+				break
+			}
+		}
+	}
+	return requests
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const metricsNamespace = "gs_prometheus_operator"
+
+var (
+	reconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "reconcile_duration_seconds",
+		Help:      "Duration of the reconciliation of each sub-resource of a Prometheus.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 15),
+	}, []string{"resource"})
+
+	configSizeBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "config_size_bytes",
+		Help:      "Size of the rendered configuration of a Prometheus, summed over its shards.",
+	}, []string{"namespace", "prometheus"})
+
+	scrapeJobs = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "scrape_jobs",
+		Help:      "Number of scrape jobs configured for a Prometheus.",
+	}, []string{"namespace", "prometheus"})
+
+	scrapeTargets = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "scrape_targets",
+		Help:      "Number of static targets configured for a Prometheus.",
+	}, []string{"namespace", "prometheus"})
+
+	validationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "validation_failures_total",
+		Help:      "Number of reconciliations of a Prometheus rejected by the validation of its spec.",
+	}, []string{"namespace", "prometheus"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(reconcileDuration, configSizeBytes, scrapeJobs, scrapeTargets, validationFailures)
+}
+
+// deleteInstanceMetrics removes the series of a deleted Prometheus.
+func deleteInstanceMetrics(namespace, name string) {
+	configSizeBytes.DeleteLabelValues(namespace, name)
+	scrapeJobs.DeleteLabelValues(namespace, name)
+	scrapeTargets.DeleteLabelValues(namespace, name)
+	validationFailures.DeleteLabelValues(namespace, name)
+}
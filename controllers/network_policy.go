@@ -0,0 +1,90 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-cmp/cmp"
+	prometheus "github.com/mcbenjemaa/gs-prometheus-operator/internal/prometheus"
+	core "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrltypes "k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	crlog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+)
+
+// apiServerEndpoints endpoints of the API server, behind the kubernetes Service of the default namespace
+var apiServerEndpoints = ctrltypes.NamespacedName{Namespace: "default", Name: "kubernetes"}
+
+// reconcileNetworkPolicy reconciles the NetworkPolicy of the Prometheus pods
+func (r *PrometheusReconciler) reconcileNetworkPolicy(ctx context.Context, p *monitoringv1alpha1.Prometheus, tg prometheus.TargetGroups) error {
+	log := crlog.FromContext(ctx)
+
+	// Retrieve NetworkPolicy
+	var np networkingv1.NetworkPolicy
+	nn := ctrltypes.NamespacedName{Namespace: p.ObjectMeta.Namespace, Name: p.ObjectMeta.Name}
+	err := r.Get(ctx, nn, &np)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	exists := err == nil
+
+	var apiServer core.Endpoints
+	if p.Spec.NetworkPolicy != nil {
+		if err := r.Get(ctx, apiServerEndpoints, &apiServer); err != nil {
+			return fmt.Errorf("unable to get API server endpoints: %v", err)
+		}
+	}
+
+	desiredNp, needed := prometheus.DesiredNetworkPolicy(p, tg, apiServer, r.OperatorNamespace)
+	switch {
+	case exists && !metav1.IsControlledBy(&np, p):
+		// Leave alone a NetworkPolicy the Prometheus does not own
+		if needed {
+			r.reportNotOwned(p, "NetworkPolicy", nn.Name)
+		}
+	case !needed && exists:
+		// Delete NetworkPolicy
+		log.Info("Delete NetworkPolicy")
+		if err := r.Delete(ctx, &np); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	case needed && !exists:
+		// Create NetworkPolicy
+		if err := ctrl.SetControllerReference(p, &desiredNp, r.Scheme); err != nil {
+			return err
+		}
+		if err := r.Create(ctx, &desiredNp); err != nil {
+			return err
+		}
+		r.recorder.Eventf(p, core.EventTypeNormal, "NetworkPolicyCreated", "NetworkPolicy %v is created", nn.Name)
+	case needed && exists:
+		// Check Diff & Update NetworkPolicy
+		if !cmp.Equal(np.Spec, desiredNp.Spec) {
+			log.Info("Update NetworkPolicy")
+			np.Spec = desiredNp.Spec
+			return r.Update(ctx, &np)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	prometheus "github.com/mcbenjemaa/gs-prometheus-operator/internal/prometheus"
+	ctrltypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+)
+
+// selectProbes adds the Probes selected by the Prometheus to its target groups
+func (r *PrometheusReconciler) selectProbes(ctx context.Context, p *monitoringv1alpha1.Prometheus, tg *prometheus.TargetGroups) error {
+	if p.Spec.ProbeSelector == nil {
+		return nil
+	}
+
+	var list monitoringv1alpha1.ProbeList
+	if err := r.List(ctx, &list); err != nil {
+		return err
+	}
+	namespaces, err := r.selectedNamespaces(ctx, p.Spec.ProbeNamespaceSelector)
+	if err != nil {
+		return err
+	}
+	items := list.Items[:0]
+	for _, probe := range list.Items {
+		if namespaces == nil || namespaces[probe.Namespace] {
+			items = append(items, probe)
+		}
+	}
+	probes, invalid, err := prometheus.SelectProbes(p, items)
+	if err != nil {
+		return err
+	}
+	tg.Probes, tg.ProbeErrors = probes, invalid
+	return nil
+}
+
+// prometheusesForProbe maps a Probe to every Prometheus selecting Probes, which may have
+// selected it before its labels changed
+func (r *PrometheusReconciler) prometheusesForProbe(obj client.Object) []reconcile.Request {
+	var list monitoringv1alpha1.PrometheusList
+	if err := r.List(context.Background(), &list); err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, p := range list.Items {
+		if p.Spec.ProbeSelector != nil {
+			requests = append(requests, reconcile.Request{NamespacedName: ctrltypes.NamespacedName{Namespace: p.Namespace, Name: p.Name}})
+		}
+	}
+	return requests
+}
@@ -19,20 +19,31 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"sort"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	prometheus "github.com/mcbenjemaa/gs-prometheus-operator/internal/prometheus"
 	appsv1 "k8s.io/api/apps/v1"
 	core "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	ctrltypes "k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	crlog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
 
 	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
 )
@@ -40,18 +51,34 @@ import (
 // PrometheusReconciler reconciles a Prometheus object
 type PrometheusReconciler struct {
 	client.Client
-	recorder record.EventRecorder
+	recorder   record.EventRecorder
+	httpClient *http.Client
 
 	Scheme *runtime.Scheme
+	// OperatorNamespace namespace of the operator pods, allowed by the NetworkPolicies to reach Prometheus
+	OperatorNamespace string
 }
 
 //+kubebuilder:rbac:groups=monitoring.giantswarm.io,resources=prometheuses,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=monitoring.giantswarm.io,resources=prometheuses/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=monitoring.giantswarm.io,resources=prometheuses/finalizers,verbs=update
+//+kubebuilder:rbac:groups=monitoring.giantswarm.io,resources=scrapetargets,verbs=get;list;watch
+//+kubebuilder:rbac:groups=monitoring.giantswarm.io,resources=scrapetargets/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=monitoring.giantswarm.io,resources=probes,verbs=get;list;watch
 
 //+kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=apps,resources=statefulsets/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=apps,resources=statefulsets/finalizers,verbs=update
+//+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
+
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=persistentvolumeclaims,verbs=get;list;watch;delete
+
+//+kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch;create;update;patch;delete
+
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes,verbs=get;list;watch;create;update;patch;delete
 
 //+kubebuilder:rbac:groups=core,resources=services;configmaps;serviceaccounts;events,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core,resources=services/status;configmaps/status,verbs=get;update;patch
@@ -62,6 +89,8 @@ type PrometheusReconciler struct {
 //+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles/finalizers;clusterrolebindings/finalizers,verbs=update
 
 //+kubebuilder:rbac:groups=core,resources=endpoints;nodes;nodes/metrics;pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups=discovery.k8s.io,resources=endpointslices,verbs=get;list;watch
 //+kubebuilder:rbac:urls=/metrics;/metrics/cadvisor,verbs=get
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
@@ -76,43 +105,123 @@ func (r *PrometheusReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	var prometheus monitoringv1alpha1.Prometheus
 	if err := r.Get(ctx, req.NamespacedName, &prometheus); err != nil {
 		log.Error(err, "unable to fetch Prometheus")
+		if apierrors.IsNotFound(err) {
+			deleteInstanceMetrics(req.Namespace, req.Name)
+			if err := r.releaseScrapeTargets(ctx, req.NamespacedName); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
 	// ensurePrometheus
-	err := r.ensurePrometheus(ctx, &prometheus)
+	result, err := r.ensurePrometheus(ctx, &prometheus)
 	if err != nil {
 		log.Error(err, "unable to ensure Prometheus %v")
 		r.recorder.Eventf(&prometheus, core.EventTypeWarning, "FailedInitializingPrometheus", "error initializing prometheus, %v", err)
 	}
 
-	return ctrl.Result{}, nil
+	return result, nil
 }
 
 // ensurePrometheus ensures Prometheus(Statefulset, Service, ConfigMap)
-func (r *PrometheusReconciler) ensurePrometheus(ctx context.Context, p *monitoringv1alpha1.Prometheus) error {
-
-	err := r.reconcileRbac(ctx, p)
+func (r *PrometheusReconciler) ensurePrometheus(ctx context.Context, p *monitoringv1alpha1.Prometheus) (ctrl.Result, error) {
+	tg, err := r.ensurePrometheusResources(ctx, p)
 	if err != nil {
-		return fmt.Errorf("unable to reconcile RBAC: %v", err)
+		return ctrl.Result{}, err
 	}
 
-	err = r.reconcileStatefulSet(ctx, p)
-	if err != nil {
-		return fmt.Errorf("unable to reconcile StatefulSet: %v", err)
+	// The target health is polled even when the reload failed
+	result, reloadErr := r.reconcileConfigReload(ctx, p, tg)
+	healthResult, healthErr := r.reconcileTargetHealth(ctx, p)
+	result = earliestRequeue(result, healthResult)
+	if reloadErr != nil {
+		return result, fmt.Errorf("unable to reload Prometheus configuration: %v", reloadErr)
 	}
+	if healthErr != nil {
+		return result, fmt.Errorf("unable to poll target health: %v", healthErr)
+	}
+	return result, nil
+}
 
-	err = r.reconcileService(ctx, p)
+// ensurePrometheusResources ensures the Kubernetes resources making up Prometheus, and returns
+// the target groups they were rendered from
+func (r *PrometheusReconciler) ensurePrometheusResources(ctx context.Context, p *monitoringv1alpha1.Prometheus) (prometheus.TargetGroups, error) {
+
+	err := prometheus.Validate(p)
+	if statusErr := r.updateValidCondition(ctx, p, err); statusErr != nil {
+		return prometheus.TargetGroups{}, statusErr
+	}
 	if err != nil {
-		return fmt.Errorf("unable to reconcile Service: %v", err)
+		validationFailures.WithLabelValues(p.Namespace, p.Name).Inc()
+		return prometheus.TargetGroups{}, fmt.Errorf("invalid Prometheus: %v", err)
 	}
 
-	err = r.reconcileConfigMaps(ctx, p)
+	// The target groups are listed once for all the resources rendered from them
+	start := time.Now()
+	tg, err := r.targetGroups(ctx, p)
+	reconcileDuration.WithLabelValues("target_groups").Observe(time.Since(start).Seconds())
 	if err != nil {
-		return fmt.Errorf("unable to reconcile ConfigMap: %v", err)
+		return prometheus.TargetGroups{}, fmt.Errorf("unable to merge target groups: %v", err)
+	}
+	withTargetGroups := func(reconcile func(context.Context, *monitoringv1alpha1.Prometheus, prometheus.TargetGroups) error) func(context.Context, *monitoringv1alpha1.Prometheus) error {
+		return func(ctx context.Context, p *monitoringv1alpha1.Prometheus) error {
+			return reconcile(ctx, p, tg)
+		}
 	}
 
-	return nil
+	steps := []struct {
+		resource  string
+		name      string
+		reconcile func(context.Context, *monitoringv1alpha1.Prometheus) error
+	}{
+		{"rbac", "RBAC", r.reconcileRbac},
+		{"web_config", "web config", r.reconcileWebConfig},
+		{"governing_service", "governing Service", r.reconcileGoverningService},
+		{"shards", "shards", r.reconcileShards},
+		{"replica_status", "replica status", r.reconcileReplicaStatus},
+		{"pod_disruption_budget", "PodDisruptionBudget", r.reconcilePodDisruptionBudget},
+		{"service", "Service", r.reconcileService},
+		{"thanos_service", "Thanos Service", r.reconcileThanosService},
+		{"network_policy", "NetworkPolicy", withTargetGroups(r.reconcileNetworkPolicy)},
+		{"ingress", "Ingress", r.reconcileIngress},
+		{"http_route", "HTTPRoute", r.reconcileHTTPRoute},
+		{"config_maps", "ConfigMap", withTargetGroups(r.reconcileConfigMaps)},
+		{"federation_status", "federation status", withTargetGroups(r.reconcileFederationStatus)},
+	}
+	for _, step := range steps {
+		start := time.Now()
+		err := step.reconcile(ctx, p)
+		reconcileDuration.WithLabelValues(step.resource).Observe(time.Since(start).Seconds())
+		if err != nil {
+			return prometheus.TargetGroups{}, fmt.Errorf("unable to reconcile %s: %v", step.name, err)
+		}
+	}
+
+	return tg, nil
+}
+
+// updateValidCondition reports the validation result of the spec in the Valid condition
+func (r *PrometheusReconciler) updateValidCondition(ctx context.Context, p *monitoringv1alpha1.Prometheus, validationErr error) error {
+	condition := metav1.Condition{
+		Type:               monitoringv1alpha1.ConditionValid,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: p.Generation,
+		Reason:             "SpecValid",
+		Message:            fmt.Sprintf("spec is supported by Prometheus %s", p.Spec.Image.Version),
+	}
+	if validationErr != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "SpecInvalid"
+		condition.Message = validationErr.Error()
+	}
+
+	current := meta.FindStatusCondition(p.Status.Conditions, condition.Type)
+	if current != nil && current.Status == condition.Status && current.Message == condition.Message && current.ObservedGeneration == condition.ObservedGeneration {
+		return nil
+	}
+	meta.SetStatusCondition(&p.Status.Conditions, condition)
+	return r.Status().Update(ctx, p)
 }
 
 func (r *PrometheusReconciler) reconcileRbac(ctx context.Context, p *monitoringv1alpha1.Prometheus) error {
@@ -130,16 +239,25 @@ func (r *PrometheusReconciler) reconcileRbac(ctx context.Context, p *monitoringv
 		return fmt.Errorf("unable to create ServiceAccount: %v", err)
 	}
 
-	// Clusterrole
+	// Clusterrole, following the roles discovered by the jobs
 	cr := prometheus.DesiredClusterRole(p)
-	if err := ctrl.SetControllerReference(p, &cr, r.Scheme); err != nil {
-		return err
-	}
-	err = r.Create(ctx, &cr)
-	if err != nil && errors.IsAlreadyExists(err) {
-		log.Info("Clusterrole already exists!")
-	} else if err != nil {
-		return fmt.Errorf("unable to create Clusterrole: %v", err)
+	var currentCr rbacv1.ClusterRole
+	if err := r.Get(ctx, ctrltypes.NamespacedName{Name: cr.Name}, &currentCr); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		if err := ctrl.SetControllerReference(p, &cr, r.Scheme); err != nil {
+			return err
+		}
+		if err := r.Create(ctx, &cr); err != nil {
+			return fmt.Errorf("unable to create Clusterrole: %v", err)
+		}
+	} else if !cmp.Equal(currentCr.Rules, cr.Rules) {
+		log.Info("Update Clusterrole")
+		currentCr.Rules = cr.Rules
+		if err := r.Update(ctx, &currentCr); err != nil {
+			return fmt.Errorf("unable to update Clusterrole: %v", err)
+		}
 	}
 
 	// ClusterRoleBinding
@@ -157,154 +275,624 @@ func (r *PrometheusReconciler) reconcileRbac(ctx context.Context, p *monitoringv
 	return nil
 }
 
-func (r *PrometheusReconciler) reconcileStatefulSet(ctx context.Context, p *monitoringv1alpha1.Prometheus) error {
+// reconcileShards reconciles the StatefulSet, or Deployment for agents without persistent storage, of each shard
+func (r *PrometheusReconciler) reconcileShards(ctx context.Context, p *monitoringv1alpha1.Prometheus) error {
+	var readyReplicas int32
+	for shard := int32(0); shard < prometheus.Shards(p); shard++ {
+		reconcile := r.reconcileStatefulSet
+		if prometheus.UsesDeployment(p) {
+			reconcile = r.reconcileDeployment
+		}
+		ready, err := reconcile(ctx, p, shard)
+		if err != nil {
+			return err
+		}
+		readyReplicas += ready
+	}
+
+	// Update Prometheus Status
+	if p.Status.ReadyReplicas != readyReplicas {
+		p.Status.ReadyReplicas = readyReplicas
+		if err := r.Status().Update(ctx, p); err != nil {
+			return err
+		}
+	}
+
+	return r.reconcileRemovedShards(ctx, p)
+}
+
+func (r *PrometheusReconciler) reconcileStatefulSet(ctx context.Context, p *monitoringv1alpha1.Prometheus, shard int32) (int32, error) {
 	log := crlog.FromContext(ctx)
 
+	desiredSts, err := prometheus.DesiredStatefulSet(p, shard)
+	if err != nil {
+		return 0, err
+	}
+
 	// Retrieve StatefulSet
 	var sts appsv1.StatefulSet
-	nn := ctrltypes.NamespacedName{Namespace: p.ObjectMeta.Namespace, Name: p.ObjectMeta.Name}
+	nn := ctrltypes.NamespacedName{Namespace: p.ObjectMeta.Namespace, Name: desiredSts.Name}
 	if err := r.Get(ctx, nn, &sts); err != nil {
 		log.Info("unable to get StatefulSet")
-		desiredSts := prometheus.DesiredStatefulSet(p)
 		if apierrors.IsNotFound(err) {
 			// Create StatefulSet
 			if err := ctrl.SetControllerReference(p, &desiredSts, r.Scheme); err != nil {
-				return err
+				return 0, err
 			}
 			if err := r.Create(ctx, &desiredSts); err != nil {
-				return err
-			} else if err == nil {
-				r.recorder.Eventf(p, core.EventTypeNormal, "PrometheusStatefulSetCreated", "StatefulSet %v is created", p.Name)
+				return 0, err
+			}
+			r.recorder.Eventf(p, core.EventTypeNormal, "PrometheusStatefulSetCreated", "StatefulSet %v is created", desiredSts.Name)
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	// The governing Service and the selector of a StatefulSet are immutable, so the StatefulSet
+	// is recreated while orphaning its pods, which the new StatefulSet adopts. The first shard
+	// selected the pods of every shard before it got its own shard label selector.
+	if sts.Spec.ServiceName != desiredSts.Spec.ServiceName || !cmp.Equal(sts.Spec.Selector, desiredSts.Spec.Selector) {
+		log.Info("Recreate Prometheus StatefulSet with its governing Service and selector", "shard", shard)
+		if err := r.Delete(ctx, &sts, client.PropagationPolicy(metav1.DeletePropagationOrphan)); err != nil && !apierrors.IsNotFound(err) {
+			return 0, err
+		}
+		return 0, nil
+	}
+
+	// Check Diff & Update StatefulSet
+	if !cmp.Equal(sts.Spec, desiredSts.Spec) {
+		log.Info("Update Prometheus StatefulSet", "shard", shard)
+		if err := ctrl.SetControllerReference(p, &desiredSts, r.Scheme); err != nil {
+			return 0, err
+		}
+		if err := r.Update(ctx, &desiredSts); err != nil {
+			return 0, err
+		}
+	}
+	return sts.Status.ReadyReplicas, nil
+}
+
+func (r *PrometheusReconciler) reconcileDeployment(ctx context.Context, p *monitoringv1alpha1.Prometheus, shard int32) (int32, error) {
+	log := crlog.FromContext(ctx)
+
+	desiredDeploy, err := prometheus.DesiredDeployment(p, shard)
+	if err != nil {
+		return 0, err
+	}
+
+	// Retrieve Deployment
+	var deploy appsv1.Deployment
+	nn := ctrltypes.NamespacedName{Namespace: p.ObjectMeta.Namespace, Name: desiredDeploy.Name}
+	if err := r.Get(ctx, nn, &deploy); err != nil {
+		if apierrors.IsNotFound(err) {
+			// Create Deployment
+			if err := ctrl.SetControllerReference(p, &desiredDeploy, r.Scheme); err != nil {
+				return 0, err
+			}
+			if err := r.Create(ctx, &desiredDeploy); err != nil {
+				return 0, err
 			}
+			r.recorder.Eventf(p, core.EventTypeNormal, "PrometheusDeploymentCreated", "Deployment %v is created", desiredDeploy.Name)
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	// Check Diff & Update Deployment
+	if !cmp.Equal(deploy.Spec, desiredDeploy.Spec) {
+		log.Info("Update Prometheus Deployment", "shard", shard)
+		if err := ctrl.SetControllerReference(p, &desiredDeploy, r.Scheme); err != nil {
+			return 0, err
+		}
+		if err := r.Update(ctx, &desiredDeploy); err != nil {
+			return 0, err
 		}
+	}
+	return deploy.Status.ReadyReplicas, nil
+}
+
+// reconcileReplicaStatus records the images running the prometheus and Thanos sidecar containers of each replica
+func (r *PrometheusReconciler) reconcileReplicaStatus(ctx context.Context, p *monitoringv1alpha1.Prometheus) error {
+	var pods core.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(p.Namespace), client.MatchingLabels(prometheus.PodLabels(p))); err != nil {
+		return err
+	}
+
+	var replicas []monitoringv1alpha1.ReplicaStatus
+	for _, pod := range pods.Items {
+		replica := monitoringv1alpha1.ReplicaStatus{Name: pod.Name}
+		running := false
+		for _, cs := range pod.Status.ContainerStatuses {
+			if cs.State.Running == nil {
+				continue
+			}
+			switch cs.Name {
+			case "prometheus":
+				replica.Image, replica.ImageID = cs.Image, cs.ImageID
+				running = true
+			case prometheus.ThanosContainerName:
+				replica.ThanosImage, replica.ThanosImageID = cs.Image, cs.ImageID
+			}
+		}
+		if running {
+			replicas = append(replicas, replica)
+		}
+	}
+	sort.Slice(replicas, func(i, j int) bool { return replicas[i].Name < replicas[j].Name })
+
+	// Update Prometheus Status
+	if !cmp.Equal(p.Status.Replicas, replicas) {
+		p.Status.Replicas = replicas
+		return r.Status().Update(ctx, p)
+	}
+	return nil
+}
+
+// reconcileRemovedShards deletes the StatefulSets, Deployments and ConfigMaps of the shards removed by
+// scaling down or by switching between StatefulSets and Deployments, and the PersistentVolumeClaims
+// of removed shards according to the ShardRetentionPolicy.
+func (r *PrometheusReconciler) reconcileRemovedShards(ctx context.Context, p *monitoringv1alpha1.Prometheus) error {
+	log := crlog.FromContext(ctx)
+	shards := prometheus.Shards(p)
+	selector := client.MatchingLabels(prometheus.PodLabels(p))
+
+	var stsList appsv1.StatefulSetList
+	if err := r.List(ctx, &stsList, client.InNamespace(p.Namespace), selector); err != nil {
+		return err
+	}
+	for i := range stsList.Items {
+		sts := &stsList.Items[i]
+		shard, ok := prometheus.ShardOf(sts.Labels)
+		if !ok && sts.Name == p.Name {
+			// StatefulSet created before sharding
+			shard, ok = 0, true
+		}
+		if !ok || (shard < shards && !prometheus.UsesDeployment(p)) || !metav1.IsControlledBy(sts, p) {
+			continue
+		}
+		log.Info("Delete StatefulSet of removed shard", "name", sts.Name)
+		if err := r.Delete(ctx, sts); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		r.recorder.Eventf(p, core.EventTypeNormal, "PrometheusShardDeleted", "StatefulSet %v is deleted", sts.Name)
+	}
+
+	var deployList appsv1.DeploymentList
+	if err := r.List(ctx, &deployList, client.InNamespace(p.Namespace), selector); err != nil {
+		return err
+	}
+	for i := range deployList.Items {
+		deploy := &deployList.Items[i]
+		shard, ok := prometheus.ShardOf(deploy.Labels)
+		if !ok || (shard < shards && prometheus.UsesDeployment(p)) || !metav1.IsControlledBy(deploy, p) {
+			continue
+		}
+		log.Info("Delete Deployment of removed shard", "name", deploy.Name)
+		if err := r.Delete(ctx, deploy); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		r.recorder.Eventf(p, core.EventTypeNormal, "PrometheusShardDeleted", "Deployment %v is deleted", deploy.Name)
+	}
+
+	var pdbList policyv1.PodDisruptionBudgetList
+	if err := r.List(ctx, &pdbList, client.InNamespace(p.Namespace), selector); err != nil {
+		return err
+	}
+	for i := range pdbList.Items {
+		pdb := &pdbList.Items[i]
+		if shard, ok := prometheus.ShardOf(pdb.Labels); !ok || shard < shards || !metav1.IsControlledBy(pdb, p) {
+			continue
+		}
+		log.Info("Delete PodDisruptionBudget of removed shard", "name", pdb.Name)
+		if err := r.Delete(ctx, pdb); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	var cmList core.ConfigMapList
+	if err := r.List(ctx, &cmList, client.InNamespace(p.Namespace), selector); err != nil {
+		return err
+	}
+	for i := range cmList.Items {
+		cm := &cmList.Items[i]
+		if shard, ok := prometheus.ShardOf(cm.Labels); !ok || shard < shards || !metav1.IsControlledBy(cm, p) {
+			continue
+		}
+		if err := r.Delete(ctx, cm); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	var pvcList core.PersistentVolumeClaimList
+	if err := r.List(ctx, &pvcList, client.InNamespace(p.Namespace)); err != nil {
 		return err
-	} else {
-		// Update Prometheus Status
-		p.Status.ReadyReplicas = sts.Status.ReadyReplicas
-		err := r.Status().Update(ctx, p)
+	}
+	retained := []string{}
+	for i := range pvcList.Items {
+		pvc := &pvcList.Items[i]
+		if shard, ok := prometheus.ShardOfVolumeClaim(p, pvc.Name); !ok || shard < shards {
+			continue
+		}
+		if p.Spec.ShardRetentionPolicy != monitoringv1alpha1.ShardRetentionDelete {
+			retained = append(retained, pvc.Name)
+			continue
+		}
+		log.Info("Delete PersistentVolumeClaim of removed shard", "name", pvc.Name)
+		if err := r.Delete(ctx, pvc); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	// Update Prometheus Status
+	sort.Strings(retained)
+	if len(retained) == 0 {
+		retained = nil
+	}
+	if !cmp.Equal(p.Status.RetainedVolumeClaims, retained) {
+		p.Status.RetainedVolumeClaims = retained
+		return r.Status().Update(ctx, p)
+	}
+	return nil
+}
+
+// reportNotOwned warns that an object the Prometheus needs is named like one it does not own,
+// which is left as is.
+func (r *PrometheusReconciler) reportNotOwned(p *monitoringv1alpha1.Prometheus, kind, name string) {
+	r.recorder.Eventf(p, core.EventTypeWarning, kind+"NotOwned", "%v %v is not owned by the Prometheus and is left as is", kind, name)
+}
+
+// reconcilePodDisruptionBudget reconciles the PodDisruptionBudget of each shard, reporting the
+// disruptions allowed by the most constrained one.
+func (r *PrometheusReconciler) reconcilePodDisruptionBudget(ctx context.Context, p *monitoringv1alpha1.Prometheus) error {
+	var disruptionsAllowed *int32
+	for shard := int32(0); shard < prometheus.Shards(p); shard++ {
+		allowed, err := r.reconcileShardPodDisruptionBudget(ctx, p, shard)
 		if err != nil {
 			return err
 		}
+		if allowed != nil && (disruptionsAllowed == nil || *allowed < *disruptionsAllowed) {
+			disruptionsAllowed = allowed
+		}
+	}
 
-		desiredSts := prometheus.DesiredStatefulSet(p)
-		// Check Diff & Update StatefulSet
-		if !cmp.Equal(sts.Spec, desiredSts.Spec) {
-			log.Info("Update Prometheus StatefulSet")
-			if err := ctrl.SetControllerReference(p, &desiredSts, r.Scheme); err != nil {
-				return err
+	// Update Prometheus Status
+	if !cmp.Equal(p.Status.DisruptionsAllowed, disruptionsAllowed) {
+		p.Status.DisruptionsAllowed = disruptionsAllowed
+		return r.Status().Update(ctx, p)
+	}
+	return nil
+}
+
+// reconcileShardPodDisruptionBudget reconciles the PodDisruptionBudget of a shard, and returns
+// the disruptions it allows, nil when there is none.
+func (r *PrometheusReconciler) reconcileShardPodDisruptionBudget(ctx context.Context, p *monitoringv1alpha1.Prometheus, shard int32) (*int32, error) {
+	log := crlog.FromContext(ctx)
+
+	// Retrieve PodDisruptionBudget
+	var pdb policyv1.PodDisruptionBudget
+	nn := ctrltypes.NamespacedName{Namespace: p.ObjectMeta.Namespace, Name: prometheus.ShardName(p, shard)}
+	err := r.Get(ctx, nn, &pdb)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+	exists := err == nil
+
+	desiredPdb, needed := prometheus.DesiredPodDisruptionBudget(p, shard)
+	switch {
+	case !needed && exists && metav1.IsControlledBy(&pdb, p):
+		// Delete PodDisruptionBudget, leaving alone one the Prometheus does not own
+		log.Info("Delete Prometheus PodDisruptionBudget", "name", nn.Name)
+		if err := r.Delete(ctx, &pdb); err != nil && !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+		r.recorder.Eventf(p, core.EventTypeNormal, "PodDisruptionBudgetDeleted", "PodDisruptionBudget %v is deleted", nn.Name)
+	case needed && !exists:
+		// Create PodDisruptionBudget
+		if err := ctrl.SetControllerReference(p, &desiredPdb, r.Scheme); err != nil {
+			return nil, err
+		}
+		if err := r.Create(ctx, &desiredPdb); err != nil {
+			return nil, err
+		}
+		r.recorder.Eventf(p, core.EventTypeNormal, "PodDisruptionBudgetCreated", "PodDisruptionBudget %v is created", nn.Name)
+	case needed && exists:
+		// Check Diff & Update PodDisruptionBudget
+		if !cmp.Equal(pdb.Spec, desiredPdb.Spec) || !cmp.Equal(pdb.Labels, desiredPdb.Labels) {
+			log.Info("Update Prometheus PodDisruptionBudget", "name", nn.Name)
+			pdb.Spec = desiredPdb.Spec
+			pdb.Labels = desiredPdb.Labels
+			if err := r.Update(ctx, &pdb); err != nil {
+				return nil, err
 			}
-			return r.Update(ctx, &desiredSts)
 		}
+	}
 
+	if needed && exists {
+		return &pdb.Status.DisruptionsAllowed, nil
 	}
-	return nil
+	return nil, nil
 }
 
 func (r *PrometheusReconciler) reconcileService(ctx context.Context, p *monitoringv1alpha1.Prometheus) error {
 	log := crlog.FromContext(ctx)
 
 	desiredSvc := prometheus.DesiredService(p)
-	if err := ctrl.SetControllerReference(p, &desiredSvc, r.Scheme); err != nil {
+
+	// Retrieve Service
+	var svc core.Service
+	nn := ctrltypes.NamespacedName{Namespace: p.ObjectMeta.Namespace, Name: desiredSvc.Name}
+	if err := r.Get(ctx, nn, &svc); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		// Create Service
+		if err := ctrl.SetControllerReference(p, &desiredSvc, r.Scheme); err != nil {
+			return err
+		}
+		if err := r.Create(ctx, &desiredSvc); err != nil {
+			return err
+		}
+		r.recorder.Eventf(p, core.EventTypeNormal, "ServiceCreated", "Service %v is created", desiredSvc.Name)
+		return nil
+	}
+
+	// Keep the node ports allocated by the API server
+	if desiredSvc.Spec.Type != core.ServiceTypeClusterIP {
+		for i, port := range desiredSvc.Spec.Ports {
+			for _, current := range svc.Spec.Ports {
+				if port.NodePort == 0 && port.Name == current.Name {
+					desiredSvc.Spec.Ports[i].NodePort = current.NodePort
+				}
+			}
+		}
+	}
+
+	// Check Diff & Update Service
+	if svc.Spec.Type != desiredSvc.Spec.Type ||
+		svc.Spec.SessionAffinity != desiredSvc.Spec.SessionAffinity ||
+		!cmp.Equal(svc.Spec.Ports, desiredSvc.Spec.Ports) ||
+		!cmp.Equal(svc.Spec.Selector, desiredSvc.Spec.Selector) ||
+		!cmp.Equal(svc.Spec.LoadBalancerSourceRanges, desiredSvc.Spec.LoadBalancerSourceRanges) ||
+		!cmp.Equal(svc.Labels, desiredSvc.Labels) ||
+		!cmp.Equal(svc.Annotations, desiredSvc.Annotations) {
+		log.Info("Update Service")
+		svc.Labels = desiredSvc.Labels
+		svc.Annotations = desiredSvc.Annotations
+		svc.Spec.Type = desiredSvc.Spec.Type
+		svc.Spec.SessionAffinity = desiredSvc.Spec.SessionAffinity
+		svc.Spec.Ports = desiredSvc.Spec.Ports
+		svc.Spec.Selector = desiredSvc.Spec.Selector
+		svc.Spec.LoadBalancerSourceRanges = desiredSvc.Spec.LoadBalancerSourceRanges
+		if desiredSvc.Spec.SessionAffinity != core.ServiceAffinityClientIP {
+			svc.Spec.SessionAffinityConfig = nil
+		}
+		if desiredSvc.Spec.Type == core.ServiceTypeClusterIP {
+			svc.Spec.ExternalTrafficPolicy = ""
+		}
+		return r.Update(ctx, &svc)
+	}
+	return nil
+}
+
+// reconcileGoverningService reconciles the headless Service governing the StatefulSets
+func (r *PrometheusReconciler) reconcileGoverningService(ctx context.Context, p *monitoringv1alpha1.Prometheus) error {
+	log := crlog.FromContext(ctx)
+
+	// Retrieve governing Service
+	var svc core.Service
+	nn := ctrltypes.NamespacedName{Namespace: p.ObjectMeta.Namespace, Name: p.ObjectMeta.Name + prometheus.GoverningServiceSuffix}
+	err := r.Get(ctx, nn, &svc)
+	if err != nil && !apierrors.IsNotFound(err) {
 		return err
 	}
-	err := r.Create(ctx, &desiredSvc)
-	if err != nil && errors.IsAlreadyExists(err) {
-		log.Info("desired Service already exists!")
-		return nil
+	exists := err == nil
+
+	desiredSvc, needed := prometheus.DesiredGoverningService(p)
+	switch {
+	case exists && !metav1.IsControlledBy(&svc, p):
+		// Leave alone a Service the Prometheus does not own
+		if needed {
+			r.reportNotOwned(p, "Service", nn.Name)
+		}
+	case !needed && exists:
+		// Delete governing Service
+		log.Info("Delete governing Service")
+		if err := r.Delete(ctx, &svc); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	case needed && !exists:
+		// Create governing Service
+		if err := ctrl.SetControllerReference(p, &desiredSvc, r.Scheme); err != nil {
+			return err
+		}
+		if err := r.Create(ctx, &desiredSvc); err != nil {
+			return err
+		}
+		r.recorder.Eventf(p, core.EventTypeNormal, "GoverningServiceCreated", "Service %v is created", nn.Name)
+	case needed && exists:
+		// Check Diff & Update governing Service
+		if !cmp.Equal(svc.Spec.Ports, desiredSvc.Spec.Ports) || !cmp.Equal(svc.Spec.Selector, desiredSvc.Spec.Selector) {
+			log.Info("Update governing Service")
+			svc.Spec.Ports = desiredSvc.Spec.Ports
+			svc.Spec.Selector = desiredSvc.Spec.Selector
+			return r.Update(ctx, &svc)
+		}
 	}
-	return err
+	return nil
 }
 
-func (r *PrometheusReconciler) reconcileConfigMaps(ctx context.Context, p *monitoringv1alpha1.Prometheus) error {
+func (r *PrometheusReconciler) reconcileThanosService(ctx context.Context, p *monitoringv1alpha1.Prometheus) error {
 	log := crlog.FromContext(ctx)
 
-	// reconcile Prometheus ConfigMap
+	// Retrieve Thanos Service
+	var svc core.Service
+	nn := ctrltypes.NamespacedName{Namespace: p.ObjectMeta.Namespace, Name: p.ObjectMeta.Name + prometheus.ThanosServiceSuffix}
+	err := r.Get(ctx, nn, &svc)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	exists := err == nil
+
+	desiredSvc, needed := prometheus.DesiredThanosService(p)
+	switch {
+	case exists && !metav1.IsControlledBy(&svc, p):
+		// Leave alone a Service the Prometheus does not own
+		if needed {
+			r.reportNotOwned(p, "Service", nn.Name)
+		}
+	case !needed && exists:
+		// Delete Thanos Service
+		log.Info("Delete Thanos Service")
+		if err := r.Delete(ctx, &svc); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	case needed && !exists:
+		// Create Thanos Service
+		if err := ctrl.SetControllerReference(p, &desiredSvc, r.Scheme); err != nil {
+			return err
+		}
+		if err := r.Create(ctx, &desiredSvc); err != nil {
+			return err
+		}
+		r.recorder.Eventf(p, core.EventTypeNormal, "ThanosServiceCreated", "Service %v is created", nn.Name)
+	case needed && exists:
+		// Check Diff & Update Thanos Service
+		if !cmp.Equal(svc.Spec.Ports, desiredSvc.Spec.Ports) {
+			log.Info("Update Thanos Service")
+			svc.Spec.Ports = desiredSvc.Spec.Ports
+			return r.Update(ctx, &svc)
+		}
+	}
+	return nil
+}
+
+func (r *PrometheusReconciler) reconcilePrometheusConfigMap(ctx context.Context, p *monitoringv1alpha1.Prometheus, shard int32, tg prometheus.TargetGroups) (int, error) {
+	log := crlog.FromContext(ctx)
+
+	desiredCm, err := prometheus.DesiredPrometheusConfigMap(p, shard, tg)
+	if err != nil {
+		return 0, err
+	}
+	size := len(desiredCm.Data[prometheus.PrometheusConfigKey])
+
 	var cm core.ConfigMap
-	nn := ctrltypes.NamespacedName{Namespace: p.ObjectMeta.Namespace, Name: p.ObjectMeta.Name + prometheus.PrometheusConfigMapSuffix}
+	nn := ctrltypes.NamespacedName{Namespace: p.ObjectMeta.Namespace, Name: desiredCm.Name}
 	if err := r.Get(ctx, nn, &cm); err != nil {
 		if apierrors.IsNotFound(err) {
-			desiredCm, err := prometheus.DesiredPrometheusConfigMap(p)
-			if err != nil {
-				return err
-			}
 			// Create ConfigMap
 			if err := ctrl.SetControllerReference(p, &desiredCm, r.Scheme); err != nil {
-				return err
+				return 0, err
 			}
 			if err := r.Create(ctx, &desiredCm); err != nil {
-				return err
-			} else if err == nil {
-				log.Info(fmt.Sprintf("ConfigMap %v is created", p.Name+prometheus.PrometheusConfigMapSuffix))
-				r.recorder.Eventf(p, core.EventTypeNormal, "PrometheusConfigCreated", "ConfigMap %v is created", p.Name+prometheus.PrometheusConfigMapSuffix)
+				return 0, err
 			}
-		} else {
-			return err
+			log.Info(fmt.Sprintf("ConfigMap %v is created", desiredCm.Name))
+			r.recorder.Eventf(p, core.EventTypeNormal, "PrometheusConfigCreated", "ConfigMap %v is created", desiredCm.Name)
+			return size, nil
 		}
-	} else {
-		desiredCm, err := prometheus.DesiredPrometheusConfigMap(p)
+		return 0, err
+	}
+
+	// Check Diff & Update
+	if !cmp.Equal(cm.Data, desiredCm.Data) || !cmp.Equal(cm.Labels, desiredCm.Labels) {
+		log.Info("Update Prometheus config ConfigMap", "shard", shard)
+		if err := ctrl.SetControllerReference(p, &desiredCm, r.Scheme); err != nil {
+			return 0, err
+		}
+		return size, r.Update(ctx, &desiredCm)
+	}
+	return size, nil
+}
+
+func (r *PrometheusReconciler) reconcileConfigMaps(ctx context.Context, p *monitoringv1alpha1.Prometheus, tg prometheus.TargetGroups) error {
+	log := crlog.FromContext(ctx)
+
+	for _, err := range tg.FileSDErrors {
+		log.Info("Ignore invalid file_sd key", "error", err.Error())
+		r.recorder.Eventf(p, core.EventTypeWarning, "InvalidFileSD", "file_sd ConfigMap key is ignored: %v", err)
+	}
+	for _, err := range tg.ProbeErrors {
+		log.Info("Ignore invalid Probe", "error", err.Error())
+		r.recorder.Eventf(p, core.EventTypeWarning, "InvalidProbe", "Probe is ignored: %v", err)
+	}
+
+	// reconcile Prometheus ConfigMap of each shard
+	var configSize int
+	for shard := int32(0); shard < prometheus.Shards(p); shard++ {
+		size, err := r.reconcilePrometheusConfigMap(ctx, p, shard, tg)
 		if err != nil {
 			return err
 		}
-		// Check Diff & Update
-		if !cmp.Equal(cm.Data, desiredCm.Data) {
-			log.Info("Update Prometheus config ConfigMap")
-			if err := ctrl.SetControllerReference(p, &desiredCm, r.Scheme); err != nil {
-				return err
-			}
-			return r.Update(ctx, &desiredCm)
-		}
+		configSize += size
 	}
+	configSizeBytes.WithLabelValues(p.Namespace, p.Name).Set(float64(configSize))
+	scrapeJobs.WithLabelValues(p.Namespace, p.Name).Set(float64(prometheus.JobCount(p, tg)))
+	scrapeTargets.WithLabelValues(p.Namespace, p.Name).Set(float64(prometheus.StaticTargetCount(p, tg)))
 
 	// reconcile targets ConfigMap
-	tcmName := p.ObjectMeta.Name + prometheus.PrometheusConfigMapTargetsSuffix
+	desiredCm, err := prometheus.DesiredTargetsConfigMap(p, tg)
+	if err != nil {
+		return err
+	}
 	var tcm core.ConfigMap
-	nncm := ctrltypes.NamespacedName{Namespace: p.ObjectMeta.Namespace, Name: tcmName}
+	nncm := ctrltypes.NamespacedName{Namespace: p.ObjectMeta.Namespace, Name: desiredCm.Name}
 	if err := r.Get(ctx, nncm, &tcm); err != nil {
-		if apierrors.IsNotFound(err) {
-			desiredCm, err := prometheus.DesiredTargetsConfigMap(p)
-			if err != nil {
-				return err
-			}
-			// Create ConfigMap
-			if err := ctrl.SetControllerReference(p, &desiredCm, r.Scheme); err != nil {
-				return err
-			}
-			if err := r.Create(ctx, &desiredCm); err != nil {
-				return err
-			} else if err == nil {
-				log.Info(fmt.Sprintf("ConfigMap %v is created", tcmName))
-				r.recorder.Eventf(p, core.EventTypeNormal, "TargetsConfigCreated", "ConfigMap %v is created", tcmName)
-			}
+		if !apierrors.IsNotFound(err) {
+			return err
 		}
-		return err
-	} else {
-		desiredCm, err := prometheus.DesiredTargetsConfigMap(p)
-		if err != nil {
+		// Create ConfigMap
+		if err := ctrl.SetControllerReference(p, &desiredCm, r.Scheme); err != nil {
 			return err
 		}
+		if err := r.Create(ctx, &desiredCm); err != nil {
+			return err
+		}
+		log.Info(fmt.Sprintf("ConfigMap %v is created", desiredCm.Name))
+		r.recorder.Eventf(p, core.EventTypeNormal, "TargetsConfigCreated", "ConfigMap %v is created", desiredCm.Name)
+	} else if !cmp.Equal(tcm.Data, desiredCm.Data) {
 		// Check Diff & Update
-		if !cmp.Equal(tcm.Data, desiredCm.Data) {
-			log.Info("Update targets ConfigMap")
-			if err := ctrl.SetControllerReference(p, &desiredCm, r.Scheme); err != nil {
-				return err
-			}
-			return r.Update(ctx, &desiredCm)
+		log.Info("Update targets ConfigMap")
+		if err := ctrl.SetControllerReference(p, &desiredCm, r.Scheme); err != nil {
+			return err
+		}
+		if err := r.Update(ctx, &desiredCm); err != nil {
+			return err
 		}
 	}
-	return nil
+
+	return r.reconcileScrapeTargetStatus(ctx, p, tg)
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *PrometheusReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	r.recorder = mgr.GetEventRecorderFor("gs-prometheus-operator")
+	r.httpClient = &http.Client{Timeout: reloadTimeout}
 
-	return ctrl.NewControllerManagedBy(mgr).
+	builder := ctrl.NewControllerManagedBy(mgr).
 		For(&monitoringv1alpha1.Prometheus{}).
 		Owns(&appsv1.StatefulSet{}).
+		Owns(&appsv1.Deployment{}).
 		Owns(&core.Service{}).
+		Owns(&policyv1.PodDisruptionBudget{}).
+		Owns(&networkingv1.Ingress{}).
+		Owns(&networkingv1.NetworkPolicy{}).
 		Owns(&core.ServiceAccount{}).
 		Owns(&rbacv1.ClusterRole{}).
 		Owns(&rbacv1.ClusterRoleBinding{}).
 		Owns(&core.ConfigMap{}).
-		Complete(r)
+		Owns(&core.Secret{}).
+		Watches(&source.Kind{Type: &core.Secret{}}, handler.EnqueueRequestsFromMapFunc(r.prometheusesForSecret)).
+		Watches(&source.Kind{Type: &core.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(r.prometheusesForFileSDConfigMap)).
+		Watches(&source.Kind{Type: &monitoringv1alpha1.ScrapeTarget{}}, handler.EnqueueRequestsFromMapFunc(r.prometheusesForScrapeTarget)).
+		Watches(&source.Kind{Type: &monitoringv1alpha1.Probe{}}, handler.EnqueueRequestsFromMapFunc(r.prometheusesForProbe)).
+		Watches(&source.Kind{Type: &core.Namespace{}}, handler.EnqueueRequestsFromMapFunc(r.prometheusesForNamespace)).
+		Watches(&source.Kind{Type: &monitoringv1alpha1.Prometheus{}}, handler.EnqueueRequestsFromMapFunc(r.prometheusesForFederatedPrometheus))
+
+	// Gateway API is optional, HTTPRoutes are only watched when it is installed
+	httpRouteKind := schema.GroupKind{Group: gatewayv1alpha2.GroupName, Kind: "HTTPRoute"}
+	if _, err := mgr.GetRESTMapper().RESTMapping(httpRouteKind, gatewayv1alpha2.GroupVersion.Version); err == nil {
+		builder = builder.Owns(&gatewayv1alpha2.HTTPRoute{})
+	}
+
+	return builder.Complete(r)
 }
@@ -0,0 +1,173 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/google/go-cmp/cmp"
+	prometheus "github.com/mcbenjemaa/gs-prometheus-operator/internal/prometheus"
+	ctrltypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crlog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+)
+
+// targetGroups returns the target groups of the Prometheus merged with the ones of the selected ScrapeTargets,
+// the selected Probes, the federation endpoints and the valid keys of its FileSDConfigMaps
+func (r *PrometheusReconciler) targetGroups(ctx context.Context, p *monitoringv1alpha1.Prometheus) (prometheus.TargetGroups, error) {
+	tg := prometheus.SpecTargetGroups(p)
+	if p.Spec.ScrapeTargetSelector != nil {
+		var list monitoringv1alpha1.ScrapeTargetList
+		if err := r.List(ctx, &list); err != nil {
+			return prometheus.TargetGroups{}, err
+		}
+		namespaces, err := r.selectedNamespaces(ctx, p.Spec.ScrapeTargetNamespaceSelector)
+		if err != nil {
+			return prometheus.TargetGroups{}, err
+		}
+		scrapeTargets := list.Items[:0]
+		for _, st := range list.Items {
+			if namespaces == nil || namespaces[st.Namespace] {
+				scrapeTargets = append(scrapeTargets, st)
+			}
+		}
+		if tg, err = prometheus.MergeTargetGroups(p, scrapeTargets); err != nil {
+			return prometheus.TargetGroups{}, err
+		}
+	}
+
+	if err := r.selectProbes(ctx, p, &tg); err != nil {
+		return prometheus.TargetGroups{}, err
+	}
+	if err := r.resolveFederation(ctx, p, &tg); err != nil {
+		return prometheus.TargetGroups{}, err
+	}
+
+	keys, invalid, err := r.fileSDKeys(ctx, p)
+	if err != nil {
+		return prometheus.TargetGroups{}, err
+	}
+	tg.FileSDKeys, tg.FileSDErrors = keys, invalid
+	return tg, nil
+}
+
+// reconcileScrapeTargetStatus records in the status of every ScrapeTarget whether the Prometheus picked it up
+func (r *PrometheusReconciler) reconcileScrapeTargetStatus(ctx context.Context, p *monitoringv1alpha1.Prometheus, tg prometheus.TargetGroups) error {
+	log := crlog.FromContext(ctx)
+
+	selected := make(map[ctrltypes.NamespacedName]bool, len(tg.Selected))
+	for _, nn := range tg.Selected {
+		selected[nn] = true
+	}
+
+	var list monitoringv1alpha1.ScrapeTargetList
+	if err := r.List(ctx, &list); err != nil {
+		return err
+	}
+	for i := range list.Items {
+		st := &list.Items[i]
+		nn := ctrltypes.NamespacedName{Namespace: st.Namespace, Name: st.Name}
+
+		var entry *monitoringv1alpha1.ScrapeTargetPrometheusStatus
+		if selected[nn] {
+			entry = &monitoringv1alpha1.ScrapeTargetPrometheusStatus{Namespace: p.Namespace, Name: p.Name, Conflicts: tg.Conflicts[nn]}
+			// The health is recorded by the polling of the targets
+			for _, s := range st.Status.Prometheuses {
+				if s.Namespace == p.Namespace && s.Name == p.Name {
+					entry.Health = s.Health
+				}
+			}
+		}
+		statuses := setScrapeTargetPrometheusStatus(st.Status.Prometheuses, p.Namespace, p.Name, entry)
+		if !cmp.Equal(st.Status.Prometheuses, statuses) {
+			log.Info("Update ScrapeTarget status", "scrapeTarget", nn)
+			st.Status.Prometheuses = statuses
+			if err := r.Status().Update(ctx, st); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// releaseScrapeTargets removes a deleted Prometheus from the status of the ScrapeTargets
+func (r *PrometheusReconciler) releaseScrapeTargets(ctx context.Context, nn ctrltypes.NamespacedName) error {
+	var list monitoringv1alpha1.ScrapeTargetList
+	if err := r.List(ctx, &list); err != nil {
+		return err
+	}
+	for i := range list.Items {
+		st := &list.Items[i]
+		statuses := setScrapeTargetPrometheusStatus(st.Status.Prometheuses, nn.Namespace, nn.Name, nil)
+		if len(statuses) != len(st.Status.Prometheuses) {
+			st.Status.Prometheuses = statuses
+			if err := r.Status().Update(ctx, st); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// setScrapeTargetPrometheusStatus replaces the entry of a Prometheus, removing it when entry is nil
+func setScrapeTargetPrometheusStatus(statuses []monitoringv1alpha1.ScrapeTargetPrometheusStatus, namespace, name string, entry *monitoringv1alpha1.ScrapeTargetPrometheusStatus) []monitoringv1alpha1.ScrapeTargetPrometheusStatus {
+	var result []monitoringv1alpha1.ScrapeTargetPrometheusStatus
+	for _, s := range statuses {
+		if s.Namespace == namespace && s.Name == name {
+			if entry != nil {
+				result = append(result, *entry)
+				entry = nil
+			}
+			continue
+		}
+		result = append(result, s)
+	}
+	if entry != nil {
+		result = append(result, *entry)
+	}
+	return result
+}
+
+// prometheusesForScrapeTarget maps a ScrapeTarget to the Prometheuses selecting it or which picked it up before
+func (r *PrometheusReconciler) prometheusesForScrapeTarget(obj client.Object) []reconcile.Request {
+	st, ok := obj.(*monitoringv1alpha1.ScrapeTarget)
+	if !ok {
+		return nil
+	}
+
+	var list monitoringv1alpha1.PrometheusList
+	if err := r.List(context.Background(), &list); err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for i := range list.Items {
+		p := &list.Items[i]
+		selects, _ := prometheus.SelectsScrapeTarget(p, st)
+		pickedUp := false
+		for _, s := range st.Status.Prometheuses {
+			pickedUp = pickedUp || (s.Namespace == p.Namespace && s.Name == p.Name)
+		}
+		if selects || pickedUp {
+			requests = append(requests, reconcile.Request{NamespacedName: ctrltypes.NamespacedName{Namespace: p.Namespace, Name: p.Name}})
+		}
+	}
+	return requests
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	prometheus "github.com/mcbenjemaa/gs-prometheus-operator/internal/prometheus"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrltypes "k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crlog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+)
+
+// targetHealthTimeout bounds the poll of the targets of a replica
+const targetHealthTimeout = 5 * time.Second
+
+// reconcileTargetHealth polls the targets of every running replica concurrently once per interval and
+// summarizes their health in the status of the Prometheus and of its ScrapeTargets.
+func (r *PrometheusReconciler) reconcileTargetHealth(ctx context.Context, p *monitoringv1alpha1.Prometheus) (ctrl.Result, error) {
+	log := crlog.FromContext(ctx)
+
+	if p.Spec.TargetHealth == nil {
+		if p.Status.TargetHealth != nil {
+			p.Status.TargetHealth = nil
+			if err := r.Status().Update(ctx, p); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{}, r.updateScrapeTargetHealth(ctx, p, nil)
+		}
+		return ctrl.Result{}, nil
+	}
+
+	interval := prometheus.TargetHealthInterval(p)
+	if p.Status.TargetHealth != nil {
+		if wait := interval - time.Since(p.Status.TargetHealth.CheckTime.Time); wait > 0 {
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+	}
+
+	password, err := r.reloaderPassword(ctx, p)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	httpClient, verified, err := r.replicaClient(ctx, p)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if password != "" && !verified {
+		return ctrl.Result{}, fmt.Errorf("refusing to send the reloader credentials to unverified replicas")
+	}
+
+	// Poll every running replica
+	var pods core.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(p.Namespace), client.MatchingLabels(prometheus.PodLabels(p))); err != nil {
+		return ctrl.Result{}, err
+	}
+	replicas := make([][]prometheus.ActiveTarget, len(pods.Items))
+	var wg sync.WaitGroup
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.Phase != core.PodRunning || pod.Status.PodIP == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, pod *core.Pod) {
+			defer wg.Done()
+			// A slow replica must not hold the reconciliation
+			pollCtx, cancel := context.WithTimeout(ctx, targetHealthTimeout)
+			defer cancel()
+			targets, err := prometheus.FetchActiveTargets(pollCtx, httpClient, prometheus.TargetsURL(p, pod.Status.PodIP), password)
+			if err != nil {
+				// The other replicas still give a partial picture
+				log.Info("unable to fetch targets", "pod", pod.Name, "error", err.Error())
+				return
+			}
+			replicas[i] = targets
+		}(i, pod)
+	}
+	wg.Wait()
+	summary := prometheus.SummarizeTargetHealth(replicas)
+
+	p.Status.TargetHealth = &monitoringv1alpha1.TargetHealthStatus{
+		CheckTime: metav1.Now(),
+		Jobs:      summary.Jobs,
+	}
+	if err := r.Status().Update(ctx, p); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: interval}, r.updateScrapeTargetHealth(ctx, p, summary.ScrapeTargets)
+}
+
+// updateScrapeTargetHealth records the health of the targets of the ScrapeTargets picked up by the Prometheus
+func (r *PrometheusReconciler) updateScrapeTargetHealth(ctx context.Context, p *monitoringv1alpha1.Prometheus, health map[ctrltypes.NamespacedName]monitoringv1alpha1.TargetHealth) error {
+	var list monitoringv1alpha1.ScrapeTargetList
+	if err := r.List(ctx, &list); err != nil {
+		return err
+	}
+	for i := range list.Items {
+		st := &list.Items[i]
+		nn := ctrltypes.NamespacedName{Namespace: st.Namespace, Name: st.Name}
+		changed := false
+		for j := range st.Status.Prometheuses {
+			s := &st.Status.Prometheuses[j]
+			if s.Namespace != p.Namespace || s.Name != p.Name {
+				continue
+			}
+			var desired *monitoringv1alpha1.TargetHealth
+			if h, ok := health[nn]; ok {
+				desired = &h
+			} else if health != nil {
+				desired = &monitoringv1alpha1.TargetHealth{}
+			}
+			if !equalTargetHealth(s.Health, desired) {
+				s.Health = desired
+				changed = true
+			}
+		}
+		if changed {
+			if err := r.Status().Update(ctx, st); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func equalTargetHealth(a, b *monitoringv1alpha1.TargetHealth) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// earliestRequeue combines the results of two reconciliations, requeuing at the earliest.
+func earliestRequeue(a, b ctrl.Result) ctrl.Result {
+	switch {
+	case a.RequeueAfter == 0:
+		return ctrl.Result{Requeue: a.Requeue || b.Requeue, RequeueAfter: b.RequeueAfter}
+	case b.RequeueAfter == 0 || a.RequeueAfter < b.RequeueAfter:
+		return ctrl.Result{Requeue: a.Requeue || b.Requeue, RequeueAfter: a.RequeueAfter}
+	}
+	return ctrl.Result{Requeue: a.Requeue || b.Requeue, RequeueAfter: b.RequeueAfter}
+}
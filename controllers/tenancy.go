@@ -0,0 +1,60 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	prometheus "github.com/mcbenjemaa/gs-prometheus-operator/internal/prometheus"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrltypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+)
+
+// selectedNamespaces returns the namespaces matching a namespace selector, nil meaning any namespace
+func (r *PrometheusReconciler) selectedNamespaces(ctx context.Context, selector *metav1.LabelSelector) (map[string]bool, error) {
+	if selector == nil {
+		return nil, nil
+	}
+	var list core.NamespaceList
+	if err := r.List(ctx, &list); err != nil {
+		return nil, err
+	}
+	return prometheus.SelectedNamespaces(selector, list.Items)
+}
+
+// prometheusesForNamespace maps a Namespace to every Prometheus selecting the namespaces of its
+// ScrapeTargets or Probes, which may have selected it before its labels changed
+func (r *PrometheusReconciler) prometheusesForNamespace(obj client.Object) []reconcile.Request {
+	var list monitoringv1alpha1.PrometheusList
+	if err := r.List(context.Background(), &list); err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for i := range list.Items {
+		p := &list.Items[i]
+		if prometheus.UsesNamespaceSelectors(p) {
+			requests = append(requests, reconcile.Request{NamespacedName: ctrltypes.NamespacedName{Namespace: p.Namespace, Name: p.Name}})
+		}
+	}
+	return requests
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+
+	"github.com/google/go-cmp/cmp"
+	prometheus "github.com/mcbenjemaa/gs-prometheus-operator/internal/prometheus"
+	core "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrltypes "k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crlog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+)
+
+// reconcileWebConfig reconciles the Secret holding the web config file of the Prometheus
+func (r *PrometheusReconciler) reconcileWebConfig(ctx context.Context, p *monitoringv1alpha1.Prometheus) error {
+	log := crlog.FromContext(ctx)
+
+	// Retrieve web config Secret
+	var secret core.Secret
+	nn := ctrltypes.NamespacedName{Namespace: p.ObjectMeta.Namespace, Name: p.ObjectMeta.Name + prometheus.WebConfigSecretSuffix}
+	err := r.Get(ctx, nn, &secret)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	exists := err == nil
+
+	if !prometheus.HasWebConfig(p) {
+		if exists {
+			// Delete web config Secret
+			log.Info("Delete web config Secret")
+			if err := r.Delete(ctx, &secret); err != nil && !apierrors.IsNotFound(err) {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var users map[string][]byte
+	if prometheus.HasBasicAuth(p) {
+		var usersSecret core.Secret
+		usersNn := ctrltypes.NamespacedName{Namespace: p.Namespace, Name: p.Spec.Web.BasicAuthUsers.Name}
+		if err := r.Get(ctx, usersNn, &usersSecret); err != nil {
+			return fmt.Errorf("unable to get basic auth users Secret: %v", err)
+		}
+		users = usersSecret.Data
+	}
+
+	// Keep the reloader password across reconciliations
+	password := string(secret.Data[prometheus.ReloaderPasswordKey])
+	if password == "" {
+		if password, err = prometheus.NewReloaderPassword(); err != nil {
+			return err
+		}
+	}
+
+	desiredSecret, err := prometheus.DesiredWebConfigSecret(p, users, password, prometheus.ReloaderPasswordHash(&secret))
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		// Create web config Secret
+		if err := ctrl.SetControllerReference(p, &desiredSecret, r.Scheme); err != nil {
+			return err
+		}
+		if err := r.Create(ctx, &desiredSecret); err != nil {
+			return err
+		}
+		r.recorder.Eventf(p, core.EventTypeNormal, "WebConfigCreated", "Secret %v is created", desiredSecret.Name)
+		return nil
+	}
+
+	// Check Diff & Update
+	if !cmp.Equal(secret.Data, desiredSecret.Data) {
+		log.Info("Update web config Secret")
+		secret.Data = desiredSecret.Data
+		return r.Update(ctx, &secret)
+	}
+	return nil
+}
+
+// reloaderPassword returns the password of the reloader user, when basic auth is required
+func (r *PrometheusReconciler) reloaderPassword(ctx context.Context, p *monitoringv1alpha1.Prometheus) (string, error) {
+	if !prometheus.HasBasicAuth(p) {
+		return "", nil
+	}
+	var secret core.Secret
+	nn := ctrltypes.NamespacedName{Namespace: p.ObjectMeta.Namespace, Name: p.ObjectMeta.Name + prometheus.WebConfigSecretSuffix}
+	if err := r.Get(ctx, nn, &secret); err != nil {
+		return "", err
+	}
+	return string(secret.Data[prometheus.ReloaderPasswordKey]), nil
+}
+
+// replicaClient returns the client reaching the replicas of the Prometheus. Replicas served over
+// TLS are verified against the CA of the web TLS config, and their certificate is not verified
+// without it. The returned bool reports whether credentials can be sent to the replicas.
+func (r *PrometheusReconciler) replicaClient(ctx context.Context, p *monitoringv1alpha1.Prometheus) (*http.Client, bool, error) {
+	if !prometheus.HasWebTLS(p) {
+		return r.httpClient, true, nil
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: true}
+	verified := false
+	if ca := p.Spec.Web.TLS.CA; ca != nil {
+		var secret core.Secret
+		if err := r.Get(ctx, ctrltypes.NamespacedName{Namespace: p.Namespace, Name: ca.Name}, &secret); err != nil {
+			return nil, false, fmt.Errorf("unable to get web TLS CA Secret: %v", err)
+		}
+		var err error
+		if tlsConfig, err = prometheus.ReplicaTLSConfig(p, secret.Data[ca.Key]); err != nil {
+			return nil, false, err
+		}
+		verified = true
+	}
+	return &http.Client{
+		Timeout: reloadTimeout,
+		Transport: &http.Transport{
+			TLSClientConfig: tlsConfig,
+			// The client is built for each reconciliation
+			DisableKeepAlives: true,
+		},
+	}, verified, nil
+}
+
+// prometheusesForSecret maps a Secret to the Prometheuses referencing it as basic auth users or web TLS CA
+func (r *PrometheusReconciler) prometheusesForSecret(obj client.Object) []reconcile.Request {
+	var list monitoringv1alpha1.PrometheusList
+	if err := r.List(context.Background(), &list, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, p := range list.Items {
+		if referencesWebSecret(&p, obj.GetName()) {
+			requests = append(requests, reconcile.Request{NamespacedName: ctrltypes.NamespacedName{Namespace: p.Namespace, Name: p.Name}})
+		}
+	}
+	return requests
+}
+
+// referencesWebSecret returns whether the Prometheus references the Secret as basic auth users or web TLS CA
+func referencesWebSecret(p *monitoringv1alpha1.Prometheus, name string) bool {
+	if prometheus.HasBasicAuth(p) && p.Spec.Web.BasicAuthUsers.Name == name {
+		return true
+	}
+	return prometheus.HasWebTLS(p) && p.Spec.Web.TLS.CA != nil && p.Spec.Web.TLS.CA.Name == name
+}
@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+)
+
+// IsAgent returns whether the Prometheus runs in agent mode.
+func IsAgent(p *monitoringv1alpha1.Prometheus) bool {
+	return p.Spec.Mode == monitoringv1alpha1.ModeAgent
+}
+
+// UsesDeployment returns whether the replicas are run by Deployments instead of StatefulSets,
+// which is the case of agents without persistent storage.
+func UsesDeployment(p *monitoringv1alpha1.Prometheus) bool {
+	return IsAgent(p) && p.Spec.EphemeralStorage
+}
+
+// agentFlag returns the flag enabling the agent mode, a feature flag before Prometheus v3.
+func agentFlag(p *monitoringv1alpha1.Prometheus) string {
+	if atLeast(p, agentFlagVersion) {
+		return "--agent"
+	}
+	return "--enable-feature=agent"
+}
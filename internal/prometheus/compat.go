@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"fmt"
+
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/util/version"
+)
+
+// feature of Prometheus only available from a minimum version
+type feature struct {
+	name       string
+	minVersion *version.Version
+}
+
+var (
+	minimumVersion = version.MustParseSemantic("v2.0.0")
+	// agentFlagVersion is the first version where the agent mode is no longer a feature flag
+	agentFlagVersion = version.MustParseSemantic("v3.0.0")
+
+	featureEndpointSlice      = feature{"the endpointslice role", version.MustParseSemantic("v2.21.0")}
+	featureHTTPSD             = feature{"http_sd_configs", version.MustParseSemantic("v2.21.0")}
+	featureWebConfig          = feature{"web TLS and basic auth", version.MustParseSemantic("v2.24.0")}
+	featureAgent              = feature{"agent mode", version.MustParseSemantic("v2.32.0")}
+	featureAttachMetadata     = feature{"attach_metadata", version.MustParseSemantic("v2.35.0")}
+	featureAttachEndpoints    = feature{"attach_metadata for the endpoints roles", version.MustParseSemantic("v2.37.0")}
+	featureNativeHistograms   = feature{"native histograms", version.MustParseSemantic("v2.40.0")}
+	featureScrapeConfigFiles  = feature{"scrape_config_files", version.MustParseSemantic("v2.43.0")}
+	featureKeepDroppedTargets = feature{"keep_dropped_targets", version.MustParseSemantic("v2.47.0")}
+)
+
+// prometheusVersion parses the version of the Prometheus image.
+func prometheusVersion(p *monitoringv1alpha1.Prometheus) (*version.Version, error) {
+	v, err := version.ParseSemantic(p.Spec.Image.Version)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse image version %q as semver: %v", p.Spec.Image.Version, err)
+	}
+	return v, nil
+}
+
+// usedFeatures returns the version dependent features used by the spec.
+func usedFeatures(p *monitoringv1alpha1.Prometheus) []feature {
+	var r []feature
+	if HasWebConfig(p) {
+		r = append(r, featureWebConfig)
+	}
+	if IsAgent(p) {
+		r = append(r, featureAgent)
+	}
+	if p.Spec.NativeHistograms {
+		r = append(r, featureNativeHistograms)
+	}
+	if len(p.Spec.ScrapeConfigFiles) > 0 {
+		r = append(r, featureScrapeConfigFiles)
+	}
+	for _, sc := range p.Spec.AdditionalScrapeConfig {
+		if sc.KeepDroppedTargets != nil {
+			r = append(r, featureKeepDroppedTargets)
+			break
+		}
+	}
+	for _, sc := range p.Spec.AdditionalScrapeConfig {
+		if len(sc.HTTPSDConfigs) > 0 {
+			r = append(r, featureHTTPSD)
+			break
+		}
+	}
+	if kubernetesSDRoles(p)[monitoringv1alpha1.KubernetesRoleEndpointSlice] {
+		r = append(r, featureEndpointSlice)
+	}
+	attach := map[monitoringv1alpha1.KubernetesRole]bool{}
+	for _, sc := range p.Spec.AdditionalScrapeConfig {
+		for _, k := range sc.KubernetesSDConfigs {
+			if k.AttachMetadata != nil {
+				attach[k.Role] = true
+			}
+		}
+	}
+	if len(attach) > 0 {
+		r = append(r, featureAttachMetadata)
+	}
+	if attach[monitoringv1alpha1.KubernetesRoleEndpoints] || attach[monitoringv1alpha1.KubernetesRoleEndpointSlice] {
+		r = append(r, featureAttachEndpoints)
+	}
+	return r
+}
+
+// validateVersion checks that the Prometheus version supports every feature used by the spec.
+func validateVersion(p *monitoringv1alpha1.Prometheus) error {
+	v, err := prometheusVersion(p)
+	if err != nil {
+		return err
+	}
+	if !v.AtLeast(minimumVersion) {
+		return fmt.Errorf("Prometheus %s is not supported, the minimum version is v%s", p.Spec.Image.Version, minimumVersion)
+	}
+	for _, f := range usedFeatures(p) {
+		if !v.AtLeast(f.minVersion) {
+			return fmt.Errorf("%s requires Prometheus v%s or later, got %s", f.name, f.minVersion, p.Spec.Image.Version)
+		}
+	}
+	return nil
+}
+
+// atLeast returns whether the Prometheus version is at least min, false when it cannot be parsed.
+func atLeast(p *monitoringv1alpha1.Prometheus, min *version.Version) bool {
+	v, err := prometheusVersion(p)
+	if err != nil {
+		return false
+	}
+	return v.AtLeast(min)
+}
@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func newTestPrometheus(version string) *monitoringv1alpha1.Prometheus {
+	repository := "prom/prometheus"
+	return &monitoringv1alpha1.Prometheus{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "monitoring"},
+		Spec: monitoringv1alpha1.PrometheusSpec{
+			Image:     monitoringv1alpha1.ImageSpec{Repository: &repository, Version: version},
+			Replicas:  1,
+			Resources: &corev1.ResourceRequirements{},
+			AdditionalScrapeConfig: []monitoringv1alpha1.ScrapeConfig{
+				{
+					JobName:       "static",
+					StaticConfigs: []monitoringv1alpha1.StaticConfig{{Targets: []string{"localhost:9090"}}},
+				},
+			},
+		},
+	}
+}
+
+// render returns the prometheus container arguments and configuration, or the validation error.
+func render(t *testing.T, p *monitoringv1alpha1.Prometheus) string {
+	if err := Validate(p); err != nil {
+		return "error: " + err.Error() + "\n"
+	}
+	sts, err := DesiredStatefulSet(p, 0)
+	if err != nil {
+		t.Fatalf("unable to render StatefulSet: %v", err)
+	}
+	cm, err := DesiredPrometheusConfigMap(p, 0, SpecTargetGroups(p))
+	if err != nil {
+		t.Fatalf("unable to render ConfigMap: %v", err)
+	}
+	c := sts.Spec.Template.Spec.Containers[containerIndex(sts.Spec.Template.Spec.Containers, "prometheus")]
+	args, err := yaml.Marshal(c.Args)
+	if err != nil {
+		t.Fatalf("unable to marshal args: %v", err)
+	}
+	return string(args) + "---\n" + cm.Data["prometheus.yml"]
+}
+
+func TestVersionMatrix(t *testing.T) {
+	versions := []string{"v2.24.1", "v2.32.1", "v2.40.0", "v2.43.0", "v2.47.0", "v3.0.0"}
+	features := map[string]func(p *monitoringv1alpha1.Prometheus){
+		"server": func(p *monitoringv1alpha1.Prometheus) {},
+		"agent": func(p *monitoringv1alpha1.Prometheus) {
+			p.Spec.Mode = monitoringv1alpha1.ModeAgent
+			p.Spec.RemoteWrite = []monitoringv1alpha1.RemoteWriteSpec{{URL: "http://remote:9090/api/v1/write"}}
+		},
+		"native-histograms": func(p *monitoringv1alpha1.Prometheus) {
+			p.Spec.NativeHistograms = true
+		},
+		"scrape-config-files": func(p *monitoringv1alpha1.Prometheus) {
+			p.Spec.ScrapeConfigFiles = []string{"/etc/scrape-configs/*.yaml"}
+		},
+		"keep-dropped-targets": func(p *monitoringv1alpha1.Prometheus) {
+			limit := int32(100)
+			p.Spec.AdditionalScrapeConfig[0].KeepDroppedTargets = &limit
+		},
+		"kubernetes-sd": func(p *monitoringv1alpha1.Prometheus) {
+			p.Spec.AdditionalScrapeConfig = append(p.Spec.AdditionalScrapeConfig, monitoringv1alpha1.ScrapeConfig{
+				JobName: "pods",
+				KubernetesSDConfigs: []monitoringv1alpha1.KubernetesSDConfig{
+					{
+						Role:           monitoringv1alpha1.KubernetesRolePod,
+						Namespaces:     []string{"apps"},
+						Selectors:      []monitoringv1alpha1.KubernetesSelector{{Role: monitoringv1alpha1.KubernetesRolePod, Field: "status.phase=Running"}},
+						AttachMetadata: &monitoringv1alpha1.AttachMetadata{Node: true},
+					},
+				},
+			})
+		},
+	}
+
+	for _, v := range versions {
+		for name, apply := range features {
+			t.Run(v+"/"+name, func(t *testing.T) {
+				p := newTestPrometheus(v)
+				apply(p)
+				assertGolden(t, filepath.Join("testdata", "versions", v, name+".golden"), render(t, p))
+			})
+		}
+	}
+}
+
+// assertGolden compares got with the golden file at path, first writing it with -update.
+func assertGolden(t *testing.T, path, got string) {
+	t.Helper()
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unable to read golden file, run with -update: %v", err)
+	}
+	if diff := cmp.Diff(string(want), got); diff != "" {
+		t.Errorf("unexpected rendering (-want +got):\n%s", diff)
+	}
+}
+
+func TestValidateVersion(t *testing.T) {
+	for version, wantErr := range map[string]string{
+		"v2.24.1": "",
+		"2.24.1":  "",
+		"v1.8.2":  "not supported",
+		"latest":  "unable to parse",
+		"v2.40":   "unable to parse",
+	} {
+		err := validateVersion(newTestPrometheus(version))
+		if wantErr == "" && err != nil {
+			t.Errorf("%s: unexpected error: %v", version, err)
+		}
+		if wantErr != "" && (err == nil || !strings.Contains(err.Error(), wantErr)) {
+			t.Errorf("%s: expected error containing %q, got %v", version, wantErr, err)
+		}
+	}
+}
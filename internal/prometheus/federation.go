@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"fmt"
+	"net/url"
+
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// FederatedPrometheus returns the Prometheus federated by a job.
+func FederatedPrometheus(p *monitoringv1alpha1.Prometheus, sc monitoringv1alpha1.ScrapeConfig) types.NamespacedName {
+	ref := sc.Federate.Prometheus
+	nn := types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}
+	if nn.Namespace == "" {
+		nn.Namespace = p.Namespace
+	}
+	return nn
+}
+
+// Federates returns whether a job of the Prometheus federates the Prometheus nn.
+func Federates(p *monitoringv1alpha1.Prometheus, nn types.NamespacedName) bool {
+	for _, sc := range p.Spec.AdditionalScrapeConfig {
+		if sc.Federate != nil && FederatedPrometheus(p, sc) == nn {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveFederation returns the status of a federation job, federated being nil when the
+// referenced Prometheus does not exist.
+func ResolveFederation(p *monitoringv1alpha1.Prometheus, sc monitoringv1alpha1.ScrapeConfig, federated *monitoringv1alpha1.Prometheus) monitoringv1alpha1.FederationStatus {
+	nn := FederatedPrometheus(p, sc)
+	status := monitoringv1alpha1.FederationStatus{JobName: sc.JobName, Prometheus: nn.String()}
+	switch {
+	case federated == nil:
+		status.Message = fmt.Sprintf("Prometheus %s not found", nn)
+	case IsAgent(federated):
+		status.Message = fmt.Sprintf("Prometheus %s runs in agent mode and stores no series", nn)
+	case Shards(federated) > 1:
+		status.Message = fmt.Sprintf("Prometheus %s is sharded, its Service does not reach every shard", nn)
+	case HasBasicAuth(federated):
+		status.Message = fmt.Sprintf("Prometheus %s requires basic auth", nn)
+	case HasWebTLS(federated):
+		// Its CA lives in the namespace of the federated Prometheus, out of reach of the pods
+		status.Message = fmt.Sprintf("Prometheus %s is served over TLS, which cannot be verified", nn)
+	default:
+		status.Endpoint = URL(federated, fmt.Sprintf("%s.%s.svc", federated.Name, federated.Namespace)) + "/federate"
+	}
+	return status
+}
+
+// applyFederation turns a job into a federation job when its endpoint is resolved. The job has
+// no target otherwise.
+func applyFederation(psc *PrometheusScrapeConfig, sc monitoringv1alpha1.ScrapeConfig, tg TargetGroups) {
+	psc.HonorLabels = true
+	psc.Params = map[string][]string{"match[]": sc.Federate.Match}
+	for _, status := range tg.Federation {
+		if status.JobName != sc.JobName || status.Endpoint == "" {
+			continue
+		}
+		u, err := url.Parse(status.Endpoint)
+		if err != nil {
+			return
+		}
+		psc.Scheme = u.Scheme
+		psc.MetricsPath = u.Path
+		psc.StaticConfigs = []StaticConfig{{Targets: []string{u.Host}}}
+	}
+}
@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestFederation(t *testing.T) {
+	p := newTestPrometheus("v2.47.0")
+	p.Name, p.Namespace = "global", "monitoring"
+	p.Spec.AdditionalScrapeConfig = []monitoringv1alpha1.ScrapeConfig{
+		{
+			JobName: "federate-team-a",
+			Federate: &monitoringv1alpha1.FederationSpec{
+				Prometheus: monitoringv1alpha1.PrometheusReference{Namespace: "team-a", Name: "prometheus"},
+				Match:      []string{`{job="app"}`, `up`},
+			},
+		},
+	}
+	federated := newTestPrometheus("v2.47.0")
+	federated.Name, federated.Namespace = "prometheus", "team-a"
+	federated.Spec.Web = &monitoringv1alpha1.WebSpec{RoutePrefix: "/prometheus"}
+
+	status := ResolveFederation(p, p.Spec.AdditionalScrapeConfig[0], federated)
+	want := monitoringv1alpha1.FederationStatus{
+		JobName:    "federate-team-a",
+		Prometheus: "team-a/prometheus",
+		Endpoint:   "http://prometheus.team-a.svc:9090/prometheus/federate",
+	}
+	if diff := cmp.Diff(want, status); diff != "" {
+		t.Errorf("unexpected federation status (-want +got):\n%s", diff)
+	}
+
+	tg := SpecTargetGroups(p)
+	tg.Federation = []monitoringv1alpha1.FederationStatus{status}
+	got, err := yaml.Marshal(scrapeConfigs(p, tg)[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantJob := `job_name: federate-team-a
+honor_labels: true
+scheme: http
+metrics_path: /prometheus/federate
+params:
+  match[]:
+  - '{job="app"}'
+  - up
+static_configs:
+- targets:
+  - prometheus.team-a.svc:9090
+`
+	if diff := cmp.Diff(wantJob, string(got)); diff != "" {
+		t.Errorf("unexpected federation job (-want +got):\n%s", diff)
+	}
+
+	federated.Spec.Web.TLS = newTestWebTLS()
+	if status := ResolveFederation(p, p.Spec.AdditionalScrapeConfig[0], federated); status.Endpoint != "" || status.Message == "" {
+		t.Errorf("expected a Prometheus served over TLS not to be resolved, got %+v", status)
+	}
+	federated.Spec.Web.TLS = nil
+	federated.Spec.Web.BasicAuthUsers = &corev1.LocalObjectReference{Name: "users"}
+	if status := ResolveFederation(p, p.Spec.AdditionalScrapeConfig[0], federated); status.Endpoint != "" || status.Message == "" {
+		t.Errorf("expected a Prometheus requiring basic auth not to be resolved, got %+v", status)
+	}
+	if status := ResolveFederation(p, p.Spec.AdditionalScrapeConfig[0], nil); status.Message != "Prometheus team-a/prometheus not found" {
+		t.Errorf("unexpected status of a missing Prometheus %+v", status)
+	}
+}
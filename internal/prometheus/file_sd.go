@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"fmt"
+	"sort"
+
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// legacyJobName job scraping the target groups without job name
+	legacyJobName        = "gs"
+	targetsDir           = "/etc/targets"
+	legacyTargetsFile    = "targets.yaml"
+	jobTargetsFileSuffix = "-targets.yaml"
+)
+
+// fileSDTargetGroup is a target group of a file_sd file
+type fileSDTargetGroup struct {
+	Targets []string          `yaml:"targets" json:"targets"`
+	Labels  map[string]string `yaml:"labels" json:"labels"`
+}
+
+func jobTargetsFile(job string) string {
+	return job + jobTargetsFileSuffix
+}
+
+// reservedJobNames returns the jobs target groups cannot be scraped by.
+func reservedJobNames(p *monitoringv1alpha1.Prometheus) map[string]bool {
+	reserved := map[string]bool{legacyJobName: true}
+	for _, sc := range p.Spec.AdditionalScrapeConfig {
+		reserved[sc.JobName] = true
+	}
+	if p.Spec.SelfMonitor {
+		reserved[selfMonitorJobName] = true
+	}
+	for _, job := range presetJobNames(p) {
+		reserved[job] = true
+	}
+	for _, cm := range p.Spec.FileSDConfigMaps {
+		reserved[FileSDJobName(cm)] = true
+	}
+	return reserved
+}
+
+// groupsByJob splits the target groups by job name, the groups of the legacy job having
+// an empty job name. The job names are sorted.
+func groupsByJob(groups []monitoringv1alpha1.PrometheusTarget) ([]string, map[string][]monitoringv1alpha1.PrometheusTarget) {
+	byJob := map[string][]monitoringv1alpha1.PrometheusTarget{}
+	var jobs []string
+	for _, group := range groups {
+		if _, ok := byJob[group.JobName]; !ok && group.JobName != "" {
+			jobs = append(jobs, group.JobName)
+		}
+		byJob[group.JobName] = append(byJob[group.JobName], group)
+	}
+	sort.Strings(jobs)
+	return jobs, byJob
+}
+
+// scrapeOptionsConflict returns whether two target groups of a job set an option to
+// different values. An option left unset by a group takes the value of the job.
+func scrapeOptionsConflict(a, b monitoringv1alpha1.PrometheusTarget) bool {
+	differ := func(x, y string) bool { return x != "" && y != "" && x != y }
+	return differ(a.ScrapeInterval, b.ScrapeInterval) ||
+		differ(a.ScrapeTimeout, b.ScrapeTimeout) ||
+		differ(a.Scheme, b.Scheme) ||
+		differ(a.MetricsPath, b.MetricsPath) ||
+		differ(a.BearerTokenFile, b.BearerTokenFile) ||
+		(a.TlsConfig != nil && b.TlsConfig != nil && *a.TlsConfig != *b.TlsConfig)
+}
+
+// mergeScrapeOptions returns the scrape options of a job, each set by any of its groups.
+func mergeScrapeOptions(groups []monitoringv1alpha1.PrometheusTarget) monitoringv1alpha1.PrometheusTarget {
+	var options monitoringv1alpha1.PrometheusTarget
+	set := func(option *string, value string) {
+		if *option == "" {
+			*option = value
+		}
+	}
+	for _, group := range groups {
+		set(&options.ScrapeInterval, group.ScrapeInterval)
+		set(&options.ScrapeTimeout, group.ScrapeTimeout)
+		set(&options.Scheme, group.Scheme)
+		set(&options.MetricsPath, group.MetricsPath)
+		set(&options.BearerTokenFile, group.BearerTokenFile)
+		if options.TlsConfig == nil {
+			options.TlsConfig = group.TlsConfig
+		}
+	}
+	return options
+}
+
+// fileSDScrapeConfigs returns a file_sd job per named job of the target groups, configured
+// with the scrape options set by its groups and the namespace relabeling of the ScrapeTargets.
+func fileSDScrapeConfigs(p *monitoringv1alpha1.Prometheus, tg TargetGroups) []PrometheusScrapeConfig {
+	jobs, byJob := groupsByJob(tg.Groups)
+	configs := make([]PrometheusScrapeConfig, 0, len(jobs))
+	for _, job := range jobs {
+		options := mergeScrapeOptions(byJob[job])
+		sc := PrometheusScrapeConfig{
+			JobName:         job,
+			ScrapeInterval:  options.ScrapeInterval,
+			ScrapeTimeout:   options.ScrapeTimeout,
+			Scheme:          options.Scheme,
+			MetricsPath:     options.MetricsPath,
+			BearerTokenFile: options.BearerTokenFile,
+			FileSdConfigs: []PrometheusFileSdConfig{
+				{Files: []string{targetsDir + "/" + jobTargetsFile(job)}},
+			},
+			RelabelConfigs: scrapeTargetNamespaceRelabelConfigs(p),
+		}
+		if options.TlsConfig != nil {
+			sc.TlsConfig = *tlsConfig(options.TlsConfig)
+		}
+		configs = append(configs, sc)
+	}
+	return configs
+}
+
+// targetsData returns the file_sd files of the targets ConfigMap, the legacy job reading
+// targets.yaml and each named job its own file.
+func targetsData(tg TargetGroups) (map[string]string, error) {
+	jobs, byJob := groupsByJob(tg.Groups)
+	data := make(map[string]string, len(jobs)+1)
+	for _, job := range append([]string{""}, jobs...) {
+		groups := make([]fileSDTargetGroup, 0, len(byJob[job]))
+		for _, group := range byJob[job] {
+			groups = append(groups, fileSDTargetGroup{Targets: group.Targets, Labels: group.Labels})
+		}
+		str, err := yaml.Marshal(groups)
+		if err != nil {
+			return nil, fmt.Errorf("unable to Marshal 'targets', %v", err)
+		}
+		file := legacyTargetsFile
+		if job != "" {
+			file = jobTargetsFile(job)
+		}
+		data[file] = string(str)
+	}
+	return data, nil
+}
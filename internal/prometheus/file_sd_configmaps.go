@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// fileSDConfigMapsDir holds a directory per FileSDConfigMap, named after the ConfigMap
+const fileSDConfigMapsDir = "/etc/file-sd"
+
+var labelNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// FileSDJobName returns the job scraping the targets of a FileSDConfigMap.
+func FileSDJobName(cm monitoringv1alpha1.FileSDConfigMap) string {
+	if cm.JobName != "" {
+		return cm.JobName
+	}
+	return cm.Name
+}
+
+// ValidateFileSD checks that the data of a ConfigMap key holds file_sd target groups, in the
+// format given by the extension of the key.
+func ValidateFileSD(key, data string) error {
+	var groups []fileSDTargetGroup
+	switch path.Ext(key) {
+	case ".json":
+		if err := json.Unmarshal([]byte(data), &groups); err != nil {
+			return fmt.Errorf("key %q is not a JSON list of target groups: %v", key, err)
+		}
+	case ".yml", ".yaml":
+		if err := yaml.UnmarshalStrict([]byte(data), &groups); err != nil {
+			return fmt.Errorf("key %q is not a YAML list of target groups: %v", key, err)
+		}
+	default:
+		return fmt.Errorf("key %q has no .json, .yml or .yaml extension", key)
+	}
+
+	for i, group := range groups {
+		for _, target := range group.Targets {
+			if target == "" {
+				return fmt.Errorf("key %q: target group %d has an empty target", key, i)
+			}
+		}
+		for name := range group.Labels {
+			if !labelNameRegexp.MatchString(name) {
+				return fmt.Errorf("key %q: target group %d has an invalid label name %q", key, i, name)
+			}
+		}
+	}
+	return nil
+}
+
+// FileSDKeys returns the sorted keys of a ConfigMap holding valid target groups, and the
+// validation errors of the other keys.
+func FileSDKeys(cm corev1.ConfigMap) ([]string, []error) {
+	keys := make([]string, 0, len(cm.Data))
+	for key := range cm.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var valid []string
+	var errs []error
+	for _, key := range keys {
+		if err := ValidateFileSD(key, cm.Data[key]); err != nil {
+			errs = append(errs, fmt.Errorf("ConfigMap %s: %v", cm.Name, err))
+			continue
+		}
+		valid = append(valid, key)
+	}
+	return valid, errs
+}
+
+// fileSDConfigMapScrapeConfigs returns a job per FileSDConfigMap holding valid keys, reading
+// them from the mounted ConfigMap.
+func fileSDConfigMapScrapeConfigs(p *monitoringv1alpha1.Prometheus, tg TargetGroups) []PrometheusScrapeConfig {
+	var r []PrometheusScrapeConfig
+	for _, cm := range p.Spec.FileSDConfigMaps {
+		keys := tg.FileSDKeys[cm.Name]
+		if len(keys) == 0 {
+			continue
+		}
+		files := make([]string, 0, len(keys))
+		for _, key := range keys {
+			files = append(files, path.Join(fileSDConfigMapsDir, cm.Name, key))
+		}
+		r = append(r, PrometheusScrapeConfig{
+			JobName:       FileSDJobName(cm),
+			FileSdConfigs: []PrometheusFileSdConfig{{Files: files}},
+		})
+	}
+	return r
+}
+
+func fileSDConfigMapVolumeName(i int) string {
+	return fmt.Sprintf("file-sd-%d", i)
+}
+
+// fileSDConfigMapVolumes mounts the whole FileSDConfigMaps, which may not exist yet. Projecting
+// the valid keys only would roll the pods whenever the keys change.
+func fileSDConfigMapVolumes(p *monitoringv1alpha1.Prometheus) []corev1.Volume {
+	optional := true
+	var v []corev1.Volume
+	for i, cm := range p.Spec.FileSDConfigMaps {
+		v = append(v, corev1.Volume{
+			Name: fileSDConfigMapVolumeName(i),
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: cm.Name},
+					Optional:             &optional,
+				},
+			},
+		})
+	}
+	return v
+}
+
+func fileSDConfigMapVolumeMounts(p *monitoringv1alpha1.Prometheus) []corev1.VolumeMount {
+	var m []corev1.VolumeMount
+	for i, cm := range p.Spec.FileSDConfigMaps {
+		m = append(m, corev1.VolumeMount{
+			Name:      fileSDConfigMapVolumeName(i),
+			MountPath: path.Join(fileSDConfigMapsDir, cm.Name),
+			ReadOnly:  true,
+		})
+	}
+	return m
+}
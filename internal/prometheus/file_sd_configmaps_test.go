@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateFileSD(t *testing.T) {
+	tests := []struct {
+		key   string
+		data  string
+		valid bool
+	}{
+		{"targets.json", `[{"targets": ["app:8080"], "labels": {"team": "a"}}]`, true},
+		{"targets.yaml", "- targets:\n  - app:8080\n  labels:\n    team: a\n", true},
+		{"targets.yml", "[]", true},
+		{"targets.txt", "[]", false},
+		{"targets.json", `{"targets": ["app:8080"]}`, false},
+		{"targets.yaml", "- target:\n  - app:8080\n", false},
+		{"targets.json", `[{"targets": [""]}]`, false},
+		{"targets.json", `[{"targets": ["app:8080"], "labels": {"team-name": "a"}}]`, false},
+	}
+	for _, tt := range tests {
+		err := ValidateFileSD(tt.key, tt.data)
+		if tt.valid && err != nil {
+			t.Errorf("%s %s: unexpected error %v", tt.key, tt.data, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("%s %s: expected an error", tt.key, tt.data)
+		}
+	}
+}
+
+func TestFileSDConfigMaps(t *testing.T) {
+	p := newTestPrometheus("v2.47.0")
+	p.Spec.FileSDConfigMaps = []monitoringv1alpha1.FileSDConfigMap{{Name: "team-a"}, {Name: "team-b", JobName: "b"}}
+
+	keys, errs := FileSDKeys(corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "team-a"},
+		Data: map[string]string{
+			"b.yaml":    "- targets: [b:8080]\n",
+			"a.json":    `[{"targets": ["a:8080"]}]`,
+			"README.md": "generated by the team tooling",
+		},
+	})
+	if diff := cmp.Diff([]string{"a.json", "b.yaml"}, keys); diff != "" {
+		t.Errorf("unexpected valid keys (-want +got):\n%s", diff)
+	}
+	if len(errs) != 1 {
+		t.Errorf("expected README.md to be invalid, got %v", errs)
+	}
+
+	// team-b has no valid key, its job is left out
+	tg := SpecTargetGroups(p)
+	tg.FileSDKeys = map[string][]string{"team-a": keys}
+	want := []PrometheusScrapeConfig{
+		{
+			JobName: "team-a",
+			FileSdConfigs: []PrometheusFileSdConfig{
+				{Files: []string{"/etc/file-sd/team-a/a.json", "/etc/file-sd/team-a/b.yaml"}},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, fileSDConfigMapScrapeConfigs(p, tg)); diff != "" {
+		t.Errorf("unexpected jobs (-want +got):\n%s", diff)
+	}
+
+	sidecar := sidecarContainer(p)
+	if diff := cmp.Diff([]string{"--volume-dir=/etc/targets", "--volume-dir=/etc/config", "--volume-dir=/etc/file-sd/team-a", "--volume-dir=/etc/file-sd/team-b"}, sidecar.Args[:4]); diff != "" {
+		t.Errorf("unexpected watched directories (-want +got):\n%s", diff)
+	}
+	if len(fileSDConfigMapVolumes(p)) != 2 || len(sidecar.VolumeMounts) != 4 {
+		t.Errorf("expected both ConfigMaps to be mounted")
+	}
+}
@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+	"gopkg.in/yaml.v2"
+)
+
+func TestNamedTargetGroups(t *testing.T) {
+	p := newTestPrometheus("v2.47.0")
+	p.Spec.Targets = []monitoringv1alpha1.PrometheusTarget{
+		{Targets: []string{"legacy:80"}},
+		{Targets: []string{"node:9100"}, JobName: "node", ScrapeInterval: "30s", MetricsPath: "/node/metrics"},
+		{Targets: []string{"node:9101"}, JobName: "node", Labels: map[string]string{"zone": "b"}},
+	}
+	tg := SpecTargetGroups(p)
+
+	data, err := targetsData(tg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantData := map[string]string{
+		"targets.yaml": "- targets:\n  - legacy:80\n  labels: {}\n",
+		"node-targets.yaml": "- targets:\n  - node:9100\n  labels: {}\n" +
+			"- targets:\n  - node:9101\n  labels:\n    zone: b\n",
+	}
+	if diff := cmp.Diff(wantData, data); diff != "" {
+		t.Errorf("unexpected targets files (-want +got):\n%s", diff)
+	}
+
+	jobs, err := yaml.Marshal(fileSDScrapeConfigs(p, tg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantJobs := `- job_name: node
+  scrape_interval: 30s
+  metrics_path: /node/metrics
+  file_sd_configs:
+  - files:
+    - /etc/targets/node-targets.yaml
+`
+	if diff := cmp.Diff(wantJobs, string(jobs)); diff != "" {
+		t.Errorf("unexpected jobs (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+)
+
+const defaultExposedPath = "/"
+
+func exposedPath(path string) string {
+	if path == "" {
+		return defaultExposedPath
+	}
+	return path
+}
+
+// exposedURL returns the URL Prometheus is reachable at through its Ingress or HTTPRoute,
+// empty when it is not exposed.
+func exposedURL(p *monitoringv1alpha1.Prometheus) string {
+	switch {
+	case p.Spec.Ingress != nil:
+		scheme := "http"
+		if p.Spec.Ingress.TLSSecretName != "" {
+			scheme = "https"
+		}
+		return fmt.Sprintf("%s://%s%s", scheme, p.Spec.Ingress.Host, strings.TrimRight(exposedPath(p.Spec.Ingress.Path), "/"))
+	case p.Spec.HTTPRoute != nil && len(p.Spec.HTTPRoute.Hostnames) > 0:
+		scheme := p.Spec.HTTPRoute.Scheme
+		if scheme == "" {
+			scheme = "https"
+		}
+		return fmt.Sprintf("%s://%s%s", scheme, p.Spec.HTTPRoute.Hostnames[0], strings.TrimRight(exposedPath(p.Spec.HTTPRoute.Path), "/"))
+	}
+	return ""
+}
+
+// DesiredIngress returns the Ingress exposing the Prometheus Service, and false when
+// no Ingress is requested.
+func DesiredIngress(p *monitoringv1alpha1.Prometheus) (networkingv1.Ingress, bool) {
+	spec := p.Spec.Ingress
+	if spec == nil {
+		return networkingv1.Ingress{}, false
+	}
+	pathType := networkingv1.PathTypePrefix
+
+	ingress := networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        p.Name,
+			Namespace:   p.Namespace,
+			Labels:      labels(p.Name),
+			Annotations: spec.Annotations,
+		},
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: spec.ClassName,
+			Rules: []networkingv1.IngressRule{
+				{
+					Host: spec.Host,
+					IngressRuleValue: networkingv1.IngressRuleValue{
+						HTTP: &networkingv1.HTTPIngressRuleValue{
+							Paths: []networkingv1.HTTPIngressPath{
+								{
+									Path:     exposedPath(spec.Path),
+									PathType: &pathType,
+									Backend: networkingv1.IngressBackend{
+										Service: &networkingv1.IngressServiceBackend{
+											Name: p.Name,
+											Port: networkingv1.ServiceBackendPort{Name: "http"},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if spec.TLSSecretName != "" {
+		ingress.Spec.TLS = []networkingv1.IngressTLS{
+			{Hosts: []string{spec.Host}, SecretName: spec.TLSSecretName},
+		}
+	}
+	return ingress, true
+}
+
+// DesiredHTTPRoute returns the Gateway API HTTPRoute exposing the Prometheus Service, and
+// false when no HTTPRoute is requested.
+func DesiredHTTPRoute(p *monitoringv1alpha1.Prometheus) (gatewayv1alpha2.HTTPRoute, bool) {
+	spec := p.Spec.HTTPRoute
+	if spec == nil {
+		return gatewayv1alpha2.HTTPRoute{}, false
+	}
+
+	// Set the defaults of the API server, so the HTTPRoute is only updated on changes
+	gatewayGroup := gatewayv1alpha2.Group(gatewayv1alpha2.GroupName)
+	gatewayKind := gatewayv1alpha2.Kind("Gateway")
+	parentRefs := make([]gatewayv1alpha2.ParentRef, 0, len(spec.ParentRefs))
+	for _, ref := range spec.ParentRefs {
+		parentRef := gatewayv1alpha2.ParentRef{
+			Group: &gatewayGroup,
+			Kind:  &gatewayKind,
+			Name:  gatewayv1alpha2.ObjectName(ref.Name),
+		}
+		if ref.Namespace != "" {
+			namespace := gatewayv1alpha2.Namespace(ref.Namespace)
+			parentRef.Namespace = &namespace
+		}
+		if ref.SectionName != "" {
+			sectionName := gatewayv1alpha2.SectionName(ref.SectionName)
+			parentRef.SectionName = &sectionName
+		}
+		parentRefs = append(parentRefs, parentRef)
+	}
+	hostnames := make([]gatewayv1alpha2.Hostname, 0, len(spec.Hostnames))
+	for _, hostname := range spec.Hostnames {
+		hostnames = append(hostnames, gatewayv1alpha2.Hostname(hostname))
+	}
+
+	pathType := gatewayv1alpha2.PathMatchPathPrefix
+	path := exposedPath(spec.Path)
+	serviceGroup := gatewayv1alpha2.Group("")
+	serviceKind := gatewayv1alpha2.Kind("Service")
+	port := gatewayv1alpha2.PortNumber(prometheusPort)
+	weight := int32(1)
+
+	return gatewayv1alpha2.HTTPRoute{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        p.Name,
+			Namespace:   p.Namespace,
+			Labels:      labels(p.Name),
+			Annotations: spec.Annotations,
+		},
+		Spec: gatewayv1alpha2.HTTPRouteSpec{
+			CommonRouteSpec: gatewayv1alpha2.CommonRouteSpec{ParentRefs: parentRefs},
+			Hostnames:       hostnames,
+			Rules: []gatewayv1alpha2.HTTPRouteRule{
+				{
+					Matches: []gatewayv1alpha2.HTTPRouteMatch{
+						{Path: &gatewayv1alpha2.HTTPPathMatch{Type: &pathType, Value: &path}},
+					},
+					BackendRefs: []gatewayv1alpha2.HTTPBackendRef{
+						{
+							BackendRef: gatewayv1alpha2.BackendRef{
+								BackendObjectReference: gatewayv1alpha2.BackendObjectReference{
+									Group: &serviceGroup,
+									Kind:  &serviceKind,
+									Name:  gatewayv1alpha2.ObjectName(p.Name),
+									Port:  &port,
+								},
+								Weight: &weight,
+							},
+						},
+					},
+				},
+			},
+		},
+	}, true
+}
@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"testing"
+
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+)
+
+func TestExternalURL(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(p *monitoringv1alpha1.Prometheus)
+		want   string
+	}{
+		{name: "not exposed", mutate: func(p *monitoringv1alpha1.Prometheus) {}},
+		{
+			name: "ingress",
+			mutate: func(p *monitoringv1alpha1.Prometheus) {
+				p.Spec.Ingress = &monitoringv1alpha1.IngressSpec{Host: "prometheus.example.org"}
+			},
+			want: "http://prometheus.example.org",
+		},
+		{
+			name: "ingress over TLS with a path",
+			mutate: func(p *monitoringv1alpha1.Prometheus) {
+				p.Spec.Ingress = &monitoringv1alpha1.IngressSpec{Host: "example.org", Path: "/prometheus/", TLSSecretName: "tls"}
+			},
+			want: "https://example.org/prometheus",
+		},
+		{
+			name: "httpRoute",
+			mutate: func(p *monitoringv1alpha1.Prometheus) {
+				p.Spec.HTTPRoute = &monitoringv1alpha1.HTTPRouteSpec{Hostnames: []string{"a.example.org", "b.example.org"}, Path: "/prometheus"}
+			},
+			want: "https://a.example.org/prometheus",
+		},
+		{
+			name: "httpRoute over HTTP",
+			mutate: func(p *monitoringv1alpha1.Prometheus) {
+				p.Spec.HTTPRoute = &monitoringv1alpha1.HTTPRouteSpec{Hostnames: []string{"a.example.org"}, Scheme: "http"}
+			},
+			want: "http://a.example.org",
+		},
+		{
+			name: "set in the spec",
+			mutate: func(p *monitoringv1alpha1.Prometheus) {
+				p.Spec.Ingress = &monitoringv1alpha1.IngressSpec{Host: "prometheus.example.org"}
+				p.Spec.Web = &monitoringv1alpha1.WebSpec{ExternalURL: "https://proxy.example.org/prometheus"}
+			},
+			want: "https://proxy.example.org/prometheus",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPrometheus("v2.47.0")
+			tt.mutate(p)
+			if got := externalURL(p); got != tt.want {
+				t.Errorf("externalURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
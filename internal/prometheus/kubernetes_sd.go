@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+const (
+	// serviceAccountTokenFile token of the service account mounted in the Prometheus pods
+	serviceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	// serviceAccountCAFile certificate authority of the cluster mounted in the Prometheus pods
+	serviceAccountCAFile = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+)
+
+var discoveryVerbs = []string{"get", "list", "watch"}
+
+// kubernetesSDConfigs returns the kubernetes_sd_configs of a job. The API server address is
+// left out for Prometheus to use its in-cluster service account.
+func kubernetesSDConfigs(sc monitoringv1alpha1.ScrapeConfig) []KubernetesSDConfig {
+	var r []KubernetesSDConfig
+	for _, k := range sc.KubernetesSDConfigs {
+		c := KubernetesSDConfig{Role: string(k.Role)}
+		if len(k.Namespaces) > 0 {
+			c.Namespaces = &KubernetesNamespaces{Names: k.Namespaces}
+		}
+		for _, s := range k.Selectors {
+			c.Selectors = append(c.Selectors, KubernetesSelector{Role: string(s.Role), Label: s.Label, Field: s.Field})
+		}
+		if k.AttachMetadata != nil {
+			c.AttachMetadata = &KubernetesAttachMetadata{Node: k.AttachMetadata.Node}
+		}
+		r = append(r, c)
+	}
+	return r
+}
+
+// bearerTokenFile returns the bearer token file of a job, defaulting to the token of the
+// service account for jobs scraping discovered Kubernetes targets over https.
+func bearerTokenFile(sc monitoringv1alpha1.ScrapeConfig) string {
+	if sc.BearerTokenFile == "" && sc.Scheme == "https" && len(sc.KubernetesSDConfigs) > 0 {
+		return serviceAccountTokenFile
+	}
+	return sc.BearerTokenFile
+}
+
+// kubernetesSDRoles returns the roles discovered by the jobs of the Prometheus.
+func kubernetesSDRoles(p *monitoringv1alpha1.Prometheus) map[monitoringv1alpha1.KubernetesRole]bool {
+	roles := map[monitoringv1alpha1.KubernetesRole]bool{}
+	for _, sc := range p.Spec.AdditionalScrapeConfig {
+		for _, k := range sc.KubernetesSDConfigs {
+			roles[k.Role] = true
+		}
+	}
+	return roles
+}
+
+// kubernetesSDRules returns the rules the ClusterRole of the Prometheus needs on top of the
+// core resources to discover the roles of its jobs.
+func kubernetesSDRules(p *monitoringv1alpha1.Prometheus) []rbacv1.PolicyRule {
+	roles := kubernetesSDRoles(p)
+	var rules []rbacv1.PolicyRule
+	if roles[monitoringv1alpha1.KubernetesRoleEndpointSlice] {
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups: []string{"discovery.k8s.io"},
+			Resources: []string{"endpointslices"},
+			Verbs:     discoveryVerbs,
+		})
+	}
+	// Probes of Ingresses may be selected
+	if roles[monitoringv1alpha1.KubernetesRoleIngress] || p.Spec.ProbeSelector != nil {
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups: []string{"networking.k8s.io"},
+			Resources: []string{"ingresses"},
+			Verbs:     discoveryVerbs,
+		})
+	}
+	return rules
+}
+
+// attachesMetadata returns whether the targets of a role can have metadata attached.
+func attachesMetadata(role monitoringv1alpha1.KubernetesRole) bool {
+	switch role {
+	case monitoringv1alpha1.KubernetesRolePod, monitoringv1alpha1.KubernetesRoleEndpoints, monitoringv1alpha1.KubernetesRoleEndpointSlice:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+	"gopkg.in/yaml.v2"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+func TestKubernetesSDScrapeConfig(t *testing.T) {
+	sc := monitoringv1alpha1.ScrapeConfig{
+		JobName: "kubelet",
+		Scheme:  "https",
+		KubernetesSDConfigs: []monitoringv1alpha1.KubernetesSDConfig{
+			{
+				Role:      monitoringv1alpha1.KubernetesRoleNode,
+				Selectors: []monitoringv1alpha1.KubernetesSelector{{Role: monitoringv1alpha1.KubernetesRoleNode, Label: "node-role.kubernetes.io/worker"}},
+			},
+		},
+	}
+
+	got, err := yaml.Marshal(getPrometheusScrapeConfig([]monitoringv1alpha1.ScrapeConfig{sc}, nil)[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `job_name: kubelet
+scheme: https
+bearer_token_file: /var/run/secrets/kubernetes.io/serviceaccount/token
+kubernetes_sd_configs:
+- role: node
+  selectors:
+  - role: node
+    label: node-role.kubernetes.io/worker
+`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("unexpected job (-want +got):\n%s", diff)
+	}
+}
+
+func TestKubernetesSDClusterRole(t *testing.T) {
+	p := newTestPrometheus("v2.47.0")
+	base := len(DesiredClusterRole(p).Rules)
+
+	p.Spec.AdditionalScrapeConfig = append(p.Spec.AdditionalScrapeConfig, monitoringv1alpha1.ScrapeConfig{
+		JobName: "discovered",
+		KubernetesSDConfigs: []monitoringv1alpha1.KubernetesSDConfig{
+			{Role: monitoringv1alpha1.KubernetesRoleEndpointSlice},
+			{Role: monitoringv1alpha1.KubernetesRoleIngress},
+			{Role: monitoringv1alpha1.KubernetesRolePod},
+		},
+	})
+	want := []rbacv1.PolicyRule{
+		{APIGroups: []string{"discovery.k8s.io"}, Resources: []string{"endpointslices"}, Verbs: []string{"get", "list", "watch"}},
+		{APIGroups: []string{"networking.k8s.io"}, Resources: []string{"ingresses"}, Verbs: []string{"get", "list", "watch"}},
+	}
+	if diff := cmp.Diff(want, DesiredClusterRole(p).Rules[base:]); diff != "" {
+		t.Errorf("unexpected extra rules (-want +got):\n%s", diff)
+	}
+}
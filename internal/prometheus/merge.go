@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+)
+
+// mergeContainers strategic merges the containers of patches into the base containers
+// of the same name, and appends the remaining ones.
+func mergeContainers(base []corev1.Container, patches []corev1.Container) ([]corev1.Container, error) {
+	r := make([]corev1.Container, 0, len(base)+len(patches))
+	r = append(r, base...)
+
+	for _, patch := range patches {
+		i := containerIndex(r, patch.Name)
+		if i < 0 {
+			r = append(r, patch)
+			continue
+		}
+		var merged corev1.Container
+		if err := strategicMerge(&r[i], &patch, &merged); err != nil {
+			return nil, fmt.Errorf("unable to merge container %q: %v", patch.Name, err)
+		}
+		r[i] = merged
+	}
+	return r, nil
+}
+
+// mergeVolumes replaces the base volumes by the volumes of overrides of the same name, and
+// appends the remaining ones. Volumes are not merged, as a merged volume would keep the
+// source of the base volume next to a different source of the override.
+func mergeVolumes(base []corev1.Volume, overrides []corev1.Volume) []corev1.Volume {
+	r := make([]corev1.Volume, 0, len(base)+len(overrides))
+	r = append(r, base...)
+
+	for _, override := range overrides {
+		if i := volumeIndex(r, override.Name); i >= 0 {
+			r[i] = override
+			continue
+		}
+		r = append(r, override)
+	}
+	return r
+}
+
+func containerIndex(containers []corev1.Container, name string) int {
+	for i := range containers {
+		if containers[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func volumeIndex(volumes []corev1.Volume, name string) int {
+	for i := range volumes {
+		if volumes[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func strategicMerge(base interface{}, patch interface{}, merged interface{}) error {
+	baseJSON, err := json.Marshal(base)
+	if err != nil {
+		return err
+	}
+	patchJSON, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	mergedJSON, err := strategicpatch.StrategicMergePatch(baseJSON, patchJSON, merged)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(mergedJSON, merged)
+}
@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestMergeContainers(t *testing.T) {
+	base := []corev1.Container{
+		{
+			Name:  "prometheus",
+			Image: "prom/prometheus:v2.47.0",
+			Args:  []string{"--config.file=/etc/config/prometheus.yml"},
+			Env:   []corev1.EnvVar{{Name: "A", Value: "a"}},
+			VolumeMounts: []corev1.VolumeMount{
+				{Name: "config-volume", MountPath: "/etc/config"},
+				{Name: "data", MountPath: "/data"},
+			},
+		},
+	}
+	tests := []struct {
+		name    string
+		patches []corev1.Container
+		want    []corev1.Container
+	}{
+		{
+			name: "no patch",
+			want: base,
+		},
+		{
+			name:    "override container",
+			patches: []corev1.Container{{Name: "prometheus", Image: "prom/prometheus:v2.48.0", Env: []corev1.EnvVar{{Name: "B", Value: "b"}}}},
+			want: []corev1.Container{
+				{
+					Name:         "prometheus",
+					Image:        "prom/prometheus:v2.48.0",
+					Args:         base[0].Args,
+					Env:          []corev1.EnvVar{{Name: "B", Value: "b"}, {Name: "A", Value: "a"}},
+					VolumeMounts: base[0].VolumeMounts,
+				},
+			},
+		},
+		{
+			name: "override volume mount",
+			patches: []corev1.Container{{Name: "prometheus", VolumeMounts: []corev1.VolumeMount{
+				{Name: "fast-data", MountPath: "/data"},
+				{Name: "rules", MountPath: "/etc/rules"},
+			}}},
+			want: []corev1.Container{
+				{
+					Name:  "prometheus",
+					Image: base[0].Image,
+					Args:  base[0].Args,
+					Env:   base[0].Env,
+					VolumeMounts: []corev1.VolumeMount{
+						{Name: "config-volume", MountPath: "/etc/config"},
+						{Name: "fast-data", MountPath: "/data"},
+						{Name: "rules", MountPath: "/etc/rules"},
+					},
+				},
+			},
+		},
+		{
+			name:    "additional container",
+			patches: []corev1.Container{{Name: "exporter", Image: "exporter:v1"}},
+			want:    append(append([]corev1.Container{}, base...), corev1.Container{Name: "exporter", Image: "exporter:v1"}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mergeContainers(base, tt.patches)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("unexpected containers (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMergeVolumes(t *testing.T) {
+	configMap := corev1.Volume{Name: "config-volume", VolumeSource: corev1.VolumeSource{
+		ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "test-config"}},
+	}}
+	data := corev1.Volume{Name: "data", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}}
+	secret := corev1.Volume{Name: "config-volume", VolumeSource: corev1.VolumeSource{
+		Secret: &corev1.SecretVolumeSource{SecretName: "test-config"},
+	}}
+	rules := corev1.Volume{Name: "rules", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}}
+	base := []corev1.Volume{configMap, data}
+
+	tests := []struct {
+		name      string
+		overrides []corev1.Volume
+		want      []corev1.Volume
+	}{
+		{name: "no override", want: base},
+		// A merged volume would have both a configMap and a secret source
+		{name: "replace source", overrides: []corev1.Volume{secret}, want: []corev1.Volume{secret, data}},
+		{name: "additional volume", overrides: []corev1.Volume{rules}, want: []corev1.Volume{configMap, data, rules}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, mergeVolumes(base, tt.overrides)); diff != "" {
+				t.Errorf("unexpected volumes (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
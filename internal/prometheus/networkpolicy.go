@@ -0,0 +1,271 @@
+package controllers
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	namespaceNameLabel = "kubernetes.io/metadata.name"
+	dnsNamespace       = "kube-system"
+	dnsPort            = 53
+)
+
+var (
+	// operatorPodLabels labels of the operator pods, reaching Prometheus to reload it
+	operatorPodLabels = map[string]string{"control-plane": "controller-manager"}
+	dnsPodLabels      = map[string]string{"k8s-app": "kube-dns"}
+)
+
+// hostDestination returns the namespace of an in-cluster host name, or its IP address.
+// Both are empty for hosts outside of the cluster. Like the DNS search path of the pods,
+// a host of two labels is taken for a service of another namespace.
+func hostDestination(host, namespace string) (string, net.IP) {
+	if ip := net.ParseIP(host); ip != nil {
+		return "", ip
+	}
+	parts := strings.Split(host, ".")
+	switch {
+	case len(parts) == 1:
+		return namespace, nil
+	case len(parts) == 2:
+		return parts[1], nil
+	case len(parts) >= 3 && (parts[2] == "svc" || parts[2] == "pod"):
+		return parts[1], nil
+	}
+	return "", nil
+}
+
+// egressDestinations returns the namespaces and IP addresses Prometheus reaches to scrape
+// its targets and write to remote storage.
+func egressDestinations(p *monitoringv1alpha1.Prometheus, tg TargetGroups) ([]string, []net.IP) {
+	var hosts []string
+	for _, sc := range p.Spec.AdditionalScrapeConfig {
+		for _, static := range sc.StaticConfigs {
+			for _, target := range static.Targets {
+				hosts = append(hosts, targetHost(target))
+			}
+		}
+		hosts = append(hosts, serviceDiscoveryHosts(sc)...)
+	}
+	for _, t := range tg.Groups {
+		for _, target := range t.Targets {
+			hosts = append(hosts, targetHost(target))
+		}
+	}
+	for _, probe := range tg.Probes {
+		hosts = append(hosts, targetHost(probe.Spec.Prober.URL))
+	}
+	for _, rw := range p.Spec.RemoteWrite {
+		if u, err := url.Parse(rw.URL); err == nil {
+			hosts = append(hosts, u.Hostname())
+		}
+	}
+
+	namespaces := map[string]bool{p.Namespace: true}
+	for _, ns := range kubernetesSDNamespaces(kubernetesSDScrapeConfigs(p)) {
+		namespaces[ns] = true
+	}
+	for _, sc := range p.Spec.AdditionalScrapeConfig {
+		if sc.Federate != nil {
+			namespaces[FederatedPrometheus(p, sc).Namespace] = true
+		}
+	}
+	for _, ns := range p.Spec.NetworkPolicy.EgressNamespaces {
+		namespaces[ns] = true
+	}
+	var ips []net.IP
+	seen := map[string]bool{}
+	for _, host := range hosts {
+		ns, ip := hostDestination(host, p.Namespace)
+		if ns != "" {
+			namespaces[ns] = true
+		}
+		if ip != nil && !seen[ip.String()] {
+			seen[ip.String()] = true
+			ips = append(ips, ip)
+		}
+	}
+
+	names := make([]string, 0, len(namespaces))
+	for ns := range namespaces {
+		names = append(names, ns)
+	}
+	sort.Strings(names)
+	return names, ips
+}
+
+// kubernetesSDScrapeConfigs returns the jobs of the Prometheus which may discover their targets
+// through the Kubernetes API, the presets included.
+func kubernetesSDScrapeConfigs(p *monitoringv1alpha1.Prometheus) []PrometheusScrapeConfig {
+	return append(getPrometheusScrapeConfig(p.Spec.AdditionalScrapeConfig, nil), presetScrapeConfigs(p)...)
+}
+
+// kubernetesSDNamespaces returns the namespaces the jobs explicitly discover their targets in.
+func kubernetesSDNamespaces(configs []PrometheusScrapeConfig) []string {
+	var namespaces []string
+	for _, sc := range configs {
+		for _, sd := range sc.KubernetesSDConfigs {
+			if sd.Namespaces != nil {
+				namespaces = append(namespaces, sd.Namespaces.Names...)
+			}
+		}
+	}
+	return namespaces
+}
+
+// validateKubernetesSDEgress checks that the NetworkPolicy lets the jobs reach the targets they
+// discover through the Kubernetes API. Only the namespaces listed by the jobs are derived, the
+// nodes and the targets of any namespace have to be allowed explicitly.
+func validateKubernetesSDEgress(p *monitoringv1alpha1.Prometheus, configs []PrometheusScrapeConfig) error {
+	spec := p.Spec.NetworkPolicy
+	if spec == nil {
+		return nil
+	}
+	for _, sc := range configs {
+		for _, sd := range sc.KubernetesSDConfigs {
+			switch {
+			case sd.Role == string(monitoringv1alpha1.KubernetesRoleNode):
+				if len(spec.EgressCIDRs) == 0 {
+					return fmt.Errorf("job %q discovers the nodes, which requires networkPolicy.egressCIDRs covering their addresses", sc.JobName)
+				}
+			case sd.Namespaces == nil && len(spec.EgressNamespaces) == 0:
+				return fmt.Errorf("job %q discovers targets in any namespace, which requires networkPolicy.egressNamespaces listing theirs", sc.JobName)
+			}
+		}
+	}
+	return nil
+}
+
+func targetHost(target string) string {
+	if host, _, err := net.SplitHostPort(target); err == nil {
+		return host
+	}
+	return target
+}
+
+func ipBlock(ip net.IP) string {
+	if ip.To4() != nil {
+		return ip.String() + "/32"
+	}
+	return ip.String() + "/128"
+}
+
+func networkPolicyPort(protocol corev1.Protocol, port int) networkingv1.NetworkPolicyPort {
+	p := intstr.FromInt(port)
+	return networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &p}
+}
+
+func apiServerEgressRule(apiServer corev1.Endpoints) networkingv1.NetworkPolicyEgressRule {
+	var rule networkingv1.NetworkPolicyEgressRule
+	for _, subset := range apiServer.Subsets {
+		for _, address := range subset.Addresses {
+			if ip := net.ParseIP(address.IP); ip != nil {
+				rule.To = append(rule.To, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: ipBlock(ip)}})
+			}
+		}
+		for _, port := range subset.Ports {
+			rule.Ports = append(rule.Ports, networkPolicyPort(port.Protocol, int(port.Port)))
+		}
+	}
+	return rule
+}
+
+// DesiredNetworkPolicy returns the NetworkPolicy of the Prometheus pods, allowing the operator
+// pods of operatorNamespace, the API server given by the endpoints of the kubernetes Service
+// and the target groups, and false when no NetworkPolicy is requested. The operator is not
+// allowed when its namespace is unknown, as when it runs outside of the cluster.
+func DesiredNetworkPolicy(p *monitoringv1alpha1.Prometheus, tg TargetGroups, apiServer corev1.Endpoints, operatorNamespace string) (networkingv1.NetworkPolicy, bool) {
+	spec := p.Spec.NetworkPolicy
+	if spec == nil {
+		return networkingv1.NetworkPolicy{}, false
+	}
+
+	webPorts := []networkingv1.NetworkPolicyPort{networkPolicyPort(corev1.ProtocolTCP, prometheusPort)}
+	var internalPeers []networkingv1.NetworkPolicyPeer
+	if operatorNamespace != "" {
+		internalPeers = append(internalPeers, networkingv1.NetworkPolicyPeer{
+			NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{namespaceNameLabel: operatorNamespace}},
+			PodSelector:       &metav1.LabelSelector{MatchLabels: operatorPodLabels},
+		})
+	}
+	if p.Spec.SelfMonitor {
+		internalPeers = append(internalPeers, networkingv1.NetworkPolicyPeer{
+			PodSelector: &metav1.LabelSelector{MatchLabels: labels(p.Name)},
+		})
+	}
+	var ingress []networkingv1.NetworkPolicyIngressRule
+	if len(internalPeers) > 0 {
+		ingress = append(ingress, networkingv1.NetworkPolicyIngressRule{From: internalPeers, Ports: webPorts})
+	}
+	if len(spec.From) > 0 {
+		ports := webPorts
+		if p.Spec.Thanos != nil {
+			grpcPort, httpPort := thanosPorts(p.Spec.Thanos)
+			ports = append(ports,
+				networkPolicyPort(corev1.ProtocolTCP, int(grpcPort)),
+				networkPolicyPort(corev1.ProtocolTCP, int(httpPort)),
+			)
+		}
+		ingress = append(ingress, networkingv1.NetworkPolicyIngressRule{From: spec.From, Ports: ports})
+	}
+
+	namespaces, ips := egressDestinations(p, tg)
+	egress := []networkingv1.NetworkPolicyEgressRule{
+		{
+			To: []networkingv1.NetworkPolicyPeer{
+				{
+					NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{namespaceNameLabel: dnsNamespace}},
+					PodSelector:       &metav1.LabelSelector{MatchLabels: dnsPodLabels},
+				},
+			},
+			Ports: []networkingv1.NetworkPolicyPort{
+				networkPolicyPort(corev1.ProtocolUDP, dnsPort),
+				networkPolicyPort(corev1.ProtocolTCP, dnsPort),
+			},
+		},
+		{
+			To: []networkingv1.NetworkPolicyPeer{
+				{
+					NamespaceSelector: &metav1.LabelSelector{
+						MatchExpressions: []metav1.LabelSelectorRequirement{
+							{Key: namespaceNameLabel, Operator: metav1.LabelSelectorOpIn, Values: namespaces},
+						},
+					},
+				},
+			},
+		},
+	}
+	if rule := apiServerEgressRule(apiServer); len(rule.To) > 0 {
+		egress = append(egress, rule)
+	}
+	var blocks []networkingv1.NetworkPolicyPeer
+	for _, ip := range ips {
+		blocks = append(blocks, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: ipBlock(ip)}})
+	}
+	for _, cidr := range spec.EgressCIDRs {
+		blocks = append(blocks, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr}})
+	}
+	if len(blocks) > 0 {
+		egress = append(egress, networkingv1.NetworkPolicyEgressRule{To: blocks})
+	}
+
+	return networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: p.Name, Namespace: p.Namespace, Labels: labels(p.Name)},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: labels(p.Name)},
+			Ingress:     ingress,
+			Egress:      egress,
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress},
+		},
+	}, true
+}
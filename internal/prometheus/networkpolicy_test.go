@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEgressDestinations(t *testing.T) {
+	p := newTestPrometheus("v2.47.0")
+	p.Spec.NetworkPolicy = &monitoringv1alpha1.NetworkPolicySpec{EgressNamespaces: []string{"logging"}}
+	p.Spec.Targets = []monitoringv1alpha1.PrometheusTarget{
+		{Targets: []string{"api.app.svc:8080", "10-0-0-1.db.pod.cluster.local:9187", "10.0.0.2:9100", "10.0.0.2:9101", "api.team-a:8080", "www.example.org:443"}},
+	}
+	p.Spec.RemoteWrite = []monitoringv1alpha1.RemoteWriteSpec{{URL: "http://mimir.mimir.svc.cluster.local/api/v1/push"}}
+
+	namespaces, ips := egressDestinations(p, SpecTargetGroups(p))
+	if diff := cmp.Diff([]string{"app", "db", "logging", "mimir", "monitoring", "team-a"}, namespaces); diff != "" {
+		t.Errorf("unexpected namespaces (-want +got):\n%s", diff)
+	}
+	if len(ips) != 1 || ipBlock(ips[0]) != "10.0.0.2/32" {
+		t.Errorf("expected the 10.0.0.2/32 block, got %v", ips)
+	}
+}
+
+func TestHostDestination(t *testing.T) {
+	tests := []struct {
+		host          string
+		wantNamespace string
+		wantIP        string
+	}{
+		{host: "api", wantNamespace: "monitoring"},
+		{host: "api.app", wantNamespace: "app"},
+		{host: "api.app.svc", wantNamespace: "app"},
+		{host: "api.app.svc.cluster.local", wantNamespace: "app"},
+		{host: "10-0-0-1.db.pod.cluster.local", wantNamespace: "db"},
+		{host: "10.0.0.2", wantIP: "10.0.0.2"},
+		{host: "prometheus.example.org"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			namespace, ip := hostDestination(tt.host, "monitoring")
+			if namespace != tt.wantNamespace {
+				t.Errorf("namespace = %q, want %q", namespace, tt.wantNamespace)
+			}
+			if got := ""; ip != nil {
+				got = ip.String()
+				if got != tt.wantIP {
+					t.Errorf("ip = %v, want %v", got, tt.wantIP)
+				}
+			} else if tt.wantIP != "" {
+				t.Errorf("ip = nil, want %v", tt.wantIP)
+			}
+		})
+	}
+}
+
+func TestKubernetesSDEgress(t *testing.T) {
+	p := newTestPrometheus("v2.47.0")
+	p.Spec.NetworkPolicy = &monitoringv1alpha1.NetworkPolicySpec{}
+	p.Spec.AdditionalScrapeConfig = []monitoringv1alpha1.ScrapeConfig{
+		{
+			JobName: "pods",
+			KubernetesSDConfigs: []monitoringv1alpha1.KubernetesSDConfig{
+				{Role: monitoringv1alpha1.KubernetesRolePod, Namespaces: []string{"app", "db"}},
+			},
+		},
+	}
+
+	namespaces, _ := egressDestinations(p, SpecTargetGroups(p))
+	if diff := cmp.Diff([]string{"app", "db", "monitoring"}, namespaces); diff != "" {
+		t.Errorf("unexpected namespaces (-want +got):\n%s", diff)
+	}
+	if err := validateKubernetesSDEgress(p, kubernetesSDScrapeConfigs(p)); err != nil {
+		t.Errorf("unexpected error for explicit namespaces: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		role   monitoringv1alpha1.KubernetesRole
+		policy monitoringv1alpha1.NetworkPolicySpec
+		valid  bool
+	}{
+		{name: "any namespace", role: monitoringv1alpha1.KubernetesRoleEndpoints},
+		{name: "any namespace allowed", role: monitoringv1alpha1.KubernetesRoleEndpoints, policy: monitoringv1alpha1.NetworkPolicySpec{EgressNamespaces: []string{"app"}}, valid: true},
+		{name: "nodes", role: monitoringv1alpha1.KubernetesRoleNode, policy: monitoringv1alpha1.NetworkPolicySpec{EgressNamespaces: []string{"app"}}},
+		{name: "nodes allowed", role: monitoringv1alpha1.KubernetesRoleNode, policy: monitoringv1alpha1.NetworkPolicySpec{EgressCIDRs: []string{"10.0.0.0/16"}}, valid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPrometheus("v2.47.0")
+			p.Spec.NetworkPolicy = &tt.policy
+			p.Spec.AdditionalScrapeConfig = []monitoringv1alpha1.ScrapeConfig{
+				{JobName: "sd", KubernetesSDConfigs: []monitoringv1alpha1.KubernetesSDConfig{{Role: tt.role}}},
+			}
+			if err := validateKubernetesSDEgress(p, kubernetesSDScrapeConfigs(p)); (err == nil) != tt.valid {
+				t.Errorf("validateKubernetesSDEgress() = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestPresetsEgress(t *testing.T) {
+	p := newTestPrometheus("v2.47.0")
+	p.Spec.NetworkPolicy = &monitoringv1alpha1.NetworkPolicySpec{}
+	p.Spec.Presets = monitoringv1alpha1.PresetsSpec{APIServer: true, CoreDNS: true}
+
+	namespaces, _ := egressDestinations(p, SpecTargetGroups(p))
+	if diff := cmp.Diff([]string{"default", "kube-system", "monitoring"}, namespaces); diff != "" {
+		t.Errorf("unexpected namespaces (-want +got):\n%s", diff)
+	}
+}
+
+func TestNetworkPolicyOperatorPeer(t *testing.T) {
+	p := newTestPrometheus("v2.47.0")
+	p.Spec.NetworkPolicy = &monitoringv1alpha1.NetworkPolicySpec{}
+
+	np, _ := DesiredNetworkPolicy(p, SpecTargetGroups(p), corev1.Endpoints{}, "operator")
+	want := []networkingv1.NetworkPolicyPeer{
+		{
+			NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{namespaceNameLabel: "operator"}},
+			PodSelector:       &metav1.LabelSelector{MatchLabels: operatorPodLabels},
+		},
+	}
+	if len(np.Spec.Ingress) != 1 {
+		t.Fatalf("expected one ingress rule, got %v", np.Spec.Ingress)
+	}
+	if diff := cmp.Diff(want, np.Spec.Ingress[0].From); diff != "" {
+		t.Errorf("unexpected operator peer (-want +got):\n%s", diff)
+	}
+
+	np, _ = DesiredNetworkPolicy(p, SpecTargetGroups(p), corev1.Endpoints{}, "")
+	if len(np.Spec.Ingress) != 0 {
+		t.Errorf("expected no ingress rule without operator namespace, got %v", np.Spec.Ingress)
+	}
+}
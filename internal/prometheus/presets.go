@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+)
+
+const (
+	kubeletJobName          = "kubelet"
+	cadvisorJobName         = "cadvisor"
+	apiServerJobName        = "apiserver"
+	kubeStateMetricsJobName = "kube-state-metrics"
+	coreDNSJobName          = "coredns"
+
+	coreDNSNamespace = "kube-system"
+)
+
+// preset is a job monitoring a component of the cluster
+type preset struct {
+	enabled func(monitoringv1alpha1.PresetsSpec) bool
+	config  func() PrometheusScrapeConfig
+}
+
+var presets = []preset{
+	{func(s monitoringv1alpha1.PresetsSpec) bool { return s.Kubelet }, kubeletScrapeConfig},
+	{func(s monitoringv1alpha1.PresetsSpec) bool { return s.Cadvisor }, cadvisorScrapeConfig},
+	{func(s monitoringv1alpha1.PresetsSpec) bool { return s.APIServer }, apiServerScrapeConfig},
+	{func(s monitoringv1alpha1.PresetsSpec) bool { return s.KubeStateMetrics }, kubeStateMetricsScrapeConfig},
+	{func(s monitoringv1alpha1.PresetsSpec) bool { return s.CoreDNS }, coreDNSScrapeConfig},
+}
+
+// presetScrapeConfigs returns the jobs of the presets enabled by the Prometheus.
+func presetScrapeConfigs(p *monitoringv1alpha1.Prometheus) []PrometheusScrapeConfig {
+	var r []PrometheusScrapeConfig
+	for _, preset := range presets {
+		if preset.enabled(p.Spec.Presets) {
+			r = append(r, preset.config())
+		}
+	}
+	return r
+}
+
+// presetJobNames returns the names of the jobs of the presets enabled by the Prometheus.
+func presetJobNames(p *monitoringv1alpha1.Prometheus) []string {
+	var r []string
+	for _, sc := range presetScrapeConfigs(p) {
+		r = append(r, sc.JobName)
+	}
+	return r
+}
+
+// kubeletScrapeConfig scrapes the kubelets on their node address, their serving certificate
+// being commonly self-signed.
+func kubeletScrapeConfig() PrometheusScrapeConfig {
+	return PrometheusScrapeConfig{
+		JobName:             kubeletJobName,
+		HonorLabels:         true,
+		Scheme:              "https",
+		TlsConfig:           TLSConfig{CAFile: serviceAccountCAFile, InsecureSkipVerify: true},
+		BearerTokenFile:     serviceAccountTokenFile,
+		KubernetesSDConfigs: []KubernetesSDConfig{{Role: string(monitoringv1alpha1.KubernetesRoleNode)}},
+		RelabelConfigs: []RelabelConfig{
+			{SourceLabels: []string{"__meta_kubernetes_node_name"}, TargetLabel: "node"},
+		},
+	}
+}
+
+// cadvisorScrapeConfig scrapes the container metrics of the kubelets like the kubelet job.
+func cadvisorScrapeConfig() PrometheusScrapeConfig {
+	sc := kubeletScrapeConfig()
+	sc.JobName = cadvisorJobName
+	sc.MetricsPath = "/metrics/cadvisor"
+	return sc
+}
+
+func apiServerScrapeConfig() PrometheusScrapeConfig {
+	return PrometheusScrapeConfig{
+		JobName:         apiServerJobName,
+		Scheme:          "https",
+		TlsConfig:       TLSConfig{CAFile: serviceAccountCAFile, ServerName: "kubernetes"},
+		BearerTokenFile: serviceAccountTokenFile,
+		KubernetesSDConfigs: []KubernetesSDConfig{
+			{Role: string(monitoringv1alpha1.KubernetesRoleEndpoints), Namespaces: &KubernetesNamespaces{Names: []string{"default"}}},
+		},
+		RelabelConfigs: []RelabelConfig{
+			{
+				SourceLabels: []string{"__meta_kubernetes_service_name", "__meta_kubernetes_endpoint_port_name"},
+				Regex:        "kubernetes;https",
+				Action:       "keep",
+			},
+		},
+	}
+}
+
+// kubeStateMetricsScrapeConfig honors the labels of kube-state-metrics, which describe the
+// objects rather than the exporter.
+func kubeStateMetricsScrapeConfig() PrometheusScrapeConfig {
+	return PrometheusScrapeConfig{
+		JobName:             kubeStateMetricsJobName,
+		HonorLabels:         true,
+		KubernetesSDConfigs: []KubernetesSDConfig{{Role: string(monitoringv1alpha1.KubernetesRoleEndpoints)}},
+		RelabelConfigs: []RelabelConfig{
+			{
+				SourceLabels: []string{"__meta_kubernetes_service_label_app_kubernetes_io_name"},
+				Regex:        "kube-state-metrics",
+				Action:       "keep",
+			},
+			{
+				SourceLabels: []string{"__meta_kubernetes_endpoint_port_name"},
+				Regex:        "http|http-metrics",
+				Action:       "keep",
+			},
+		},
+	}
+}
+
+func coreDNSScrapeConfig() PrometheusScrapeConfig {
+	return PrometheusScrapeConfig{
+		JobName: coreDNSJobName,
+		KubernetesSDConfigs: []KubernetesSDConfig{
+			{Role: string(monitoringv1alpha1.KubernetesRoleEndpoints), Namespaces: &KubernetesNamespaces{Names: []string{coreDNSNamespace}}},
+		},
+		RelabelConfigs: []RelabelConfig{
+			{
+				SourceLabels: []string{"__meta_kubernetes_service_label_k8s_app", "__meta_kubernetes_endpoint_port_name"},
+				Regex:        "kube-dns;metrics",
+				Action:       "keep",
+			},
+			{SourceLabels: []string{"__meta_kubernetes_pod_name"}, TargetLabel: "pod"},
+		},
+	}
+}
@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+	"gopkg.in/yaml.v2"
+)
+
+func TestPresetScrapeConfigs(t *testing.T) {
+	p := newTestPrometheus("v2.47.0")
+	p.Spec.Presets = monitoringv1alpha1.PresetsSpec{Cadvisor: true, APIServer: true}
+
+	got, err := yaml.Marshal(presetScrapeConfigs(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `- job_name: cadvisor
+  honor_labels: true
+  scheme: https
+  metrics_path: /metrics/cadvisor
+  tls_config:
+    ca_file: /var/run/secrets/kubernetes.io/serviceaccount/ca.crt
+    insecure_skip_verify: true
+  bearer_token_file: /var/run/secrets/kubernetes.io/serviceaccount/token
+  kubernetes_sd_configs:
+  - role: node
+  relabel_configs:
+  - source_labels: [__meta_kubernetes_node_name]
+    target_label: node
+- job_name: apiserver
+  scheme: https
+  tls_config:
+    ca_file: /var/run/secrets/kubernetes.io/serviceaccount/ca.crt
+    server_name: kubernetes
+    insecure_skip_verify: false
+  bearer_token_file: /var/run/secrets/kubernetes.io/serviceaccount/token
+  kubernetes_sd_configs:
+  - role: endpoints
+    namespaces:
+      names:
+      - default
+  relabel_configs:
+  - source_labels: [__meta_kubernetes_service_name, __meta_kubernetes_endpoint_port_name]
+    regex: kubernetes;https
+    action: keep
+`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("unexpected preset jobs (-want +got):\n%s", diff)
+	}
+
+	p.Spec.Presets = monitoringv1alpha1.PresetsSpec{Kubelet: true, Cadvisor: true, APIServer: true, KubeStateMetrics: true, CoreDNS: true}
+	if JobCount(p, SpecTargetGroups(p)) != 7 {
+		t.Errorf("expected the static, gs and preset jobs, got %d jobs", JobCount(p, SpecTargetGroups(p)))
+	}
+}
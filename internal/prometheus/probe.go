@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"fmt"
+	"net"
+	"sort"
+
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8slabels "k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	defaultProbeModule = "http_2xx"
+	defaultProberPath  = "/probe"
+)
+
+// ProbeJobName returns the job probing the targets of a Probe.
+func ProbeJobName(probe *monitoringv1alpha1.Probe) string {
+	return fmt.Sprintf("probe/%s/%s", probe.Namespace, probe.Name)
+}
+
+// SelectsProbe returns whether the ProbeSelector of the Prometheus selects the Probe.
+func SelectsProbe(p *monitoringv1alpha1.Prometheus, probe *monitoringv1alpha1.Probe) (bool, error) {
+	if p.Spec.ProbeSelector == nil {
+		return false, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(p.Spec.ProbeSelector)
+	if err != nil {
+		return false, err
+	}
+	return selector.Matches(k8slabels.Set(probe.Labels)), nil
+}
+
+// ValidateProbe checks the Probe for combinations the operator cannot render.
+func ValidateProbe(probe *monitoringv1alpha1.Probe) error {
+	if host, port, err := net.SplitHostPort(probe.Spec.Prober.URL); err != nil || host == "" || port == "" {
+		return fmt.Errorf("probe %s/%s prober URL %q is not a host:port address", probe.Namespace, probe.Name, probe.Spec.Prober.URL)
+	}
+	t := probe.Spec.Targets
+	if (t.StaticConfig == nil) == (t.Ingress == nil) {
+		return fmt.Errorf("probe %s/%s requires either static or ingress targets", probe.Namespace, probe.Name)
+	}
+	if t.Ingress != nil {
+		if _, err := metav1.LabelSelectorAsSelector(&t.Ingress.Selector); err != nil {
+			return fmt.Errorf("probe %s/%s has an invalid ingress selector: %v", probe.Namespace, probe.Name, err)
+		}
+	}
+	return nil
+}
+
+// SelectProbes returns the valid Probes selected by the Prometheus sorted by namespace and
+// name, and the validation errors of the invalid ones.
+func SelectProbes(p *monitoringv1alpha1.Prometheus, probes []monitoringv1alpha1.Probe) ([]monitoringv1alpha1.Probe, []error, error) {
+	var selected []monitoringv1alpha1.Probe
+	var invalid []error
+	for i := range probes {
+		ok, err := SelectsProbe(p, &probes[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			continue
+		}
+		if err := ValidateProbe(&probes[i]); err != nil {
+			invalid = append(invalid, err)
+			continue
+		}
+		selected = append(selected, probes[i])
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		if selected[i].Namespace != selected[j].Namespace {
+			return selected[i].Namespace < selected[j].Namespace
+		}
+		return selected[i].Name < selected[j].Name
+	})
+	return selected, invalid, nil
+}
+
+// probeScrapeConfigs returns the jobs of the selected Probes.
+func probeScrapeConfigs(p *monitoringv1alpha1.Prometheus, tg TargetGroups) []PrometheusScrapeConfig {
+	configs := make([]PrometheusScrapeConfig, 0, len(tg.Probes))
+	for i := range tg.Probes {
+		configs = append(configs, probeScrapeConfig(p, &tg.Probes[i]))
+	}
+	return configs
+}
+
+// probeScrapeConfig returns the job scraping the blackbox exporter once per target, passing
+// the target as the target parameter and keeping it as the instance label.
+func probeScrapeConfig(p *monitoringv1alpha1.Prometheus, probe *monitoringv1alpha1.Probe) PrometheusScrapeConfig {
+	spec := probe.Spec
+	module := spec.Module
+	if module == "" {
+		module = defaultProbeModule
+	}
+	path := spec.Prober.Path
+	if path == "" {
+		path = defaultProberPath
+	}
+
+	sc := PrometheusScrapeConfig{
+		JobName:        ProbeJobName(probe),
+		ScrapeInterval: spec.Interval,
+		ScrapeTimeout:  spec.ScrapeTimeout,
+		Scheme:         spec.Prober.Scheme,
+		MetricsPath:    path,
+		Params:         map[string][]string{"module": {module}},
+	}
+
+	var relabelConfigs []RelabelConfig
+	if static := spec.Targets.StaticConfig; static != nil {
+		sc.StaticConfigs = []StaticConfig{{Targets: static.Static, Labels: static.Labels}}
+		relabelConfigs = []RelabelConfig{
+			{SourceLabels: []string{"__address__"}, TargetLabel: "__param_target"},
+		}
+	} else {
+		ingress := spec.Targets.Ingress
+		sd := KubernetesSDConfig{
+			Role:       string(monitoringv1alpha1.KubernetesRoleIngress),
+			Namespaces: &KubernetesNamespaces{Names: probeIngressNamespaces(p, probe)},
+		}
+		// The selector is validated when selecting the Probe
+		if selector, err := metav1.LabelSelectorAsSelector(&ingress.Selector); err == nil && !selector.Empty() {
+			sd.Selectors = []KubernetesSelector{{Role: string(monitoringv1alpha1.KubernetesRoleIngress), Label: selector.String()}}
+		}
+		sc.KubernetesSDConfigs = []KubernetesSDConfig{sd}
+		relabelConfigs = []RelabelConfig{
+			{
+				SourceLabels: []string{"__meta_kubernetes_ingress_scheme", "__address__", "__meta_kubernetes_ingress_path"},
+				Regex:        "(.+);(.+);(.+)",
+				Replacement:  "${1}://${2}${3}",
+				TargetLabel:  "__param_target",
+			},
+			{SourceLabels: []string{"__meta_kubernetes_namespace"}, TargetLabel: "namespace"},
+			{SourceLabels: []string{"__meta_kubernetes_ingress_name"}, TargetLabel: "ingress"},
+		}
+	}
+	sc.RelabelConfigs = append(relabelConfigs,
+		RelabelConfig{SourceLabels: []string{"__param_target"}, TargetLabel: "instance"},
+		RelabelConfig{TargetLabel: "__address__", Replacement: spec.Prober.URL},
+	)
+	sc.RelabelConfigs = append(sc.RelabelConfigs, probeNamespaceRelabelConfigs(p, probe)...)
+	return sc
+}
@@ -0,0 +1,172 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+	"gopkg.in/yaml.v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestProbe(name string, targets monitoringv1alpha1.ProbeTargets) monitoringv1alpha1.Probe {
+	return monitoringv1alpha1.Probe{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "web", Labels: map[string]string{"probe": "true"}},
+		Spec: monitoringv1alpha1.ProbeSpec{
+			Prober:  monitoringv1alpha1.ProberSpec{URL: "blackbox.monitoring.svc:9115"},
+			Targets: targets,
+		},
+	}
+}
+
+func TestProbeScrapeConfig(t *testing.T) {
+	p := newTestPrometheus("v2.47.0")
+	static := newTestProbe("static", monitoringv1alpha1.ProbeTargets{
+		StaticConfig: &monitoringv1alpha1.ProbeStaticConfig{Static: []string{"https://example.org"}, Labels: map[string]string{"team": "web"}},
+	})
+	static.Spec.Interval = "30s"
+	ingress := newTestProbe("ingress", monitoringv1alpha1.ProbeTargets{
+		Ingress: &monitoringv1alpha1.ProbeIngress{Selector: metav1.LabelSelector{MatchLabels: map[string]string{"public": "true"}}},
+	})
+	ingress.Spec.Module = "http_post_2xx"
+
+	got, err := yaml.Marshal([]PrometheusScrapeConfig{probeScrapeConfig(p, &static), probeScrapeConfig(p, &ingress)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `- job_name: probe/web/static
+  scrape_interval: 30s
+  metrics_path: /probe
+  params:
+    module:
+    - http_2xx
+  static_configs:
+  - targets:
+    - https://example.org
+    labels:
+      team: web
+  relabel_configs:
+  - source_labels: [__address__]
+    target_label: __param_target
+  - source_labels: [__param_target]
+    target_label: instance
+  - target_label: __address__
+    replacement: blackbox.monitoring.svc:9115
+- job_name: probe/web/ingress
+  metrics_path: /probe
+  params:
+    module:
+    - http_post_2xx
+  kubernetes_sd_configs:
+  - role: ingress
+    namespaces:
+      names:
+      - web
+    selectors:
+    - role: ingress
+      label: public=true
+  relabel_configs:
+  - source_labels: [__meta_kubernetes_ingress_scheme, __address__, __meta_kubernetes_ingress_path]
+    regex: (.+);(.+);(.+)
+    target_label: __param_target
+    replacement: ${1}://${2}${3}
+  - source_labels: [__meta_kubernetes_namespace]
+    target_label: namespace
+  - source_labels: [__meta_kubernetes_ingress_name]
+    target_label: ingress
+  - source_labels: [__param_target]
+    target_label: instance
+  - target_label: __address__
+    replacement: blackbox.monitoring.svc:9115
+`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("unexpected probe jobs (-want +got):\n%s", diff)
+	}
+}
+
+func TestSelectProbes(t *testing.T) {
+	p := newTestPrometheus("v2.47.0")
+	static := monitoringv1alpha1.ProbeTargets{StaticConfig: &monitoringv1alpha1.ProbeStaticConfig{Static: []string{"example.org:443"}}}
+	probes := []monitoringv1alpha1.Probe{
+		newTestProbe("b", static),
+		newTestProbe("a", static),
+		newTestProbe("invalid", monitoringv1alpha1.ProbeTargets{}),
+	}
+
+	selected, invalid, err := SelectProbes(p, probes)
+	if err != nil || len(selected) != 0 || len(invalid) != 0 {
+		t.Fatalf("expected no Probe without selector, got %v %v %v", selected, invalid, err)
+	}
+
+	p.Spec.ProbeSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"probe": "true"}}
+	selected, invalid, err = SelectProbes(p, probes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(selected) != 2 || selected[0].Name != "a" || selected[1].Name != "b" {
+		t.Errorf("expected the valid Probes sorted by name, got %v", selected)
+	}
+	if len(invalid) != 1 {
+		t.Errorf("expected the Probe without targets to be invalid, got %v", invalid)
+	}
+
+	tg := SpecTargetGroups(p)
+	tg.Probes = selected
+	if JobCount(p, tg) != 4 {
+		t.Errorf("expected the static, gs and probe jobs, got %d jobs", JobCount(p, tg))
+	}
+}
+
+func TestValidateProbe(t *testing.T) {
+	static := monitoringv1alpha1.ProbeTargets{StaticConfig: &monitoringv1alpha1.ProbeStaticConfig{Static: []string{"example.org:443"}}}
+	tests := []struct {
+		name    string
+		url     string
+		targets monitoringv1alpha1.ProbeTargets
+		valid   bool
+	}{
+		{name: "valid", url: "blackbox.monitoring.svc:9115", targets: static, valid: true},
+		{name: "no targets", url: "blackbox.monitoring.svc:9115"},
+		{name: "prober URL with scheme", url: "http://blackbox.monitoring.svc:9115", targets: static},
+		{name: "prober URL without port", url: "blackbox.monitoring.svc", targets: static},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probe := newTestProbe("probe", tt.targets)
+			probe.Spec.Prober.URL = tt.url
+			if err := ValidateProbe(&probe); (err == nil) != tt.valid {
+				t.Errorf("ValidateProbe() = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestProbeShardRelabelConfigs(t *testing.T) {
+	p := newTestShardedPrometheus(2)
+	probe := newTestProbe("static", monitoringv1alpha1.ProbeTargets{
+		StaticConfig: &monitoringv1alpha1.ProbeStaticConfig{Static: []string{"https://example.org"}},
+	})
+	tg := SpecTargetGroups(p)
+	tg.Probes = []monitoringv1alpha1.Probe{probe}
+
+	cm, err := DesiredPrometheusConfigMap(p, 0, tg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg PrometheusConfigFile
+	if err := yaml.Unmarshal([]byte(cm.Data[PrometheusConfigKey]), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	for _, sc := range cfg.ScrapeConfigs {
+		if sc.JobName != ProbeJobName(&probe) {
+			continue
+		}
+		// Every target is rewritten to the prober address, which would put them all on the same shard
+		hash := sc.RelabelConfigs[len(sc.RelabelConfigs)-2]
+		if diff := cmp.Diff([]string{"__param_target"}, hash.SourceLabels); diff != "" {
+			t.Errorf("unexpected shard hash source (-want +got):\n%s", diff)
+		}
+		return
+	}
+	t.Error("probe job not rendered")
+}
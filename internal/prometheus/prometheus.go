@@ -1,12 +1,18 @@
 package controllers
 
 import (
+	"crypto/sha256"
 	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
 
 	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
 	"gopkg.in/yaml.v2"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
@@ -14,8 +20,14 @@ import (
 
 const (
 	prometheusPort                   = 9090
+	nobodyID                         = 65534
+	configReloaderPort               = 9533
+	configReloaderRepository         = "jimmidyson/configmap-reload"
+	configReloaderVersion            = "v0.6.1"
+	prometheusRepository             = "prom/prometheus"
 	PrometheusConfigMapTargetsSuffix = "-targets"
 	PrometheusConfigMapSuffix        = "-config"
+	PrometheusConfigKey              = "prometheus.yml"
 )
 
 func labels(name string) map[string]string {
@@ -25,15 +37,101 @@ func labels(name string) map[string]string {
 	}
 }
 
-func sidecarContainer() corev1.Container {
+// PodLabels returns the labels selecting the Prometheus pods.
+func PodLabels(p *monitoringv1alpha1.Prometheus) map[string]string {
+	return labels(p.Name)
+}
+
+func podSecurityContext(p *monitoringv1alpha1.Prometheus) *corev1.PodSecurityContext {
+	if p.Spec.SecurityContext != nil {
+		return p.Spec.SecurityContext.DeepCopy()
+	}
+	id := int64(nobodyID)
+	runAsNonRoot := true
+	return &corev1.PodSecurityContext{
+		RunAsNonRoot: &runAsNonRoot,
+		RunAsUser:    &id,
+		RunAsGroup:   &id,
+		FSGroup:      &id,
+		SeccompProfile: &corev1.SeccompProfile{
+			Type: corev1.SeccompProfileTypeRuntimeDefault,
+		},
+	}
+}
+
+func containerSecurityContext(p *monitoringv1alpha1.Prometheus) *corev1.SecurityContext {
+	if p.Spec.ContainerSecurityContext != nil {
+		return p.Spec.ContainerSecurityContext.DeepCopy()
+	}
+	readOnlyRootFilesystem := true
+	allowPrivilegeEscalation := false
+	return &corev1.SecurityContext{
+		ReadOnlyRootFilesystem:   &readOnlyRootFilesystem,
+		AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+		Capabilities: &corev1.Capabilities{
+			Drop: []corev1.Capability{"ALL"},
+		},
+	}
+}
+
+// imageReference returns the reference of an image, pinned by digest when given.
+func imageReference(repository, version, digest string) string {
+	if digest != "" {
+		return repository + "@" + digest
+	}
+	return repository + ":" + version
+}
+
+func pullPolicy(policy corev1.PullPolicy) corev1.PullPolicy {
+	if policy == "" {
+		return corev1.PullIfNotPresent
+	}
+	return policy
+}
+
+func sidecarContainer(p *monitoringv1alpha1.Prometheus) corev1.Container {
+	cr := p.Spec.ConfigReloader
+	repository, version := cr.Repository, cr.Version
+	if repository == "" {
+		repository = configReloaderRepository
+	}
+	if version == "" {
+		version = configReloaderVersion
+	}
+	resources := corev1.ResourceRequirements{}
+	if cr.Resources != nil {
+		resources = *cr.Resources.DeepCopy()
+	}
+
+	webhookURL := ReloadURL(p, "127.0.0.1")
+	var env []corev1.EnvVar
+	if HasBasicAuth(p) {
+		// the password is expanded by the kubelet from the environment
+		scheme := strings.ToLower(string(webScheme(p)))
+		webhookURL = strings.Replace(webhookURL, scheme+"://", scheme+"://"+ReloaderUsername+":$(RELOADER_PASSWORD)@", 1)
+		env = append(env, reloaderPasswordEnv(p))
+	}
+
 	return corev1.Container{
-		Name:  "configmap-reload",
-		Image: "jimmidyson/configmap-reload:v0.6.1",
-		Args: []string{"--volume-dir=/etc/targets",
-			"--volume-dir=/etc/config",
-			"--webhook-url=http://127.0.0.1:9090/-/reload",
+		Name:            "configmap-reload",
+		Image:           imageReference(repository, version, cr.Digest),
+		ImagePullPolicy: pullPolicy(cr.ImagePullPolicy),
+		SecurityContext: containerSecurityContext(p),
+		Env:             env,
+		Args:            append(sidecarArgs(p, webhookURL), cr.Args...),
+		Ports:           []corev1.ContainerPort{{Name: "reloader-web", ContainerPort: configReloaderPort}},
+		Resources:       resources,
+		LivenessProbe: &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{
+				HTTPGet: &corev1.HTTPGetAction{
+					Path: "/metrics",
+					Port: intstr.FromInt(configReloaderPort),
+				},
+			},
+			InitialDelaySeconds: 10,
+			TimeoutSeconds:      10,
 		},
-		VolumeMounts: []corev1.VolumeMount{
+		VolumeMounts: append([]corev1.VolumeMount{
 			{
 				Name:      "targets-volume",
 				MountPath: "/etc/targets",
@@ -44,43 +142,106 @@ func sidecarContainer() corev1.Container {
 				MountPath: "/etc/config/",
 				ReadOnly:  true,
 			},
-		},
+		}, fileSDConfigMapVolumeMounts(p)...),
 	}
 }
 
+// sidecarArgs returns the arguments of the reload sidecar, watching the configuration and
+// every mounted file_sd directory.
+func sidecarArgs(p *monitoringv1alpha1.Prometheus, webhookURL string) []string {
+	args := []string{"--volume-dir=/etc/targets", "--volume-dir=/etc/config"}
+	for _, m := range fileSDConfigMapVolumeMounts(p) {
+		args = append(args, "--volume-dir="+m.MountPath)
+	}
+	return append(args,
+		"--webhook-url="+webhookURL,
+		fmt.Sprintf("--web.listen-address=:%d", configReloaderPort),
+	)
+}
+
+func containers(p *monitoringv1alpha1.Prometheus) []corev1.Container {
+	r := make([]corev1.Container, 0, 3)
+	if ReloadStrategy(p) == monitoringv1alpha1.ConfigReloaderSidecar {
+		r = append(r, sidecarContainer(p))
+	}
+	r = append(r, prometheusContainer(p))
+	if p.Spec.Thanos != nil {
+		r = append(r, thanosContainer(p))
+	}
+	return r
+}
+
+// URL returns the base URL of the Prometheus reachable on host.
+func URL(p *monitoringv1alpha1.Prometheus, host string) string {
+	return fmt.Sprintf("%s://%s%s", strings.ToLower(string(webScheme(p))), net.JoinHostPort(host, strconv.Itoa(prometheusPort)), routePrefix(p))
+}
+
+// ReloadURL returns the URL of the reload endpoint of the Prometheus reachable on host.
+func ReloadURL(p *monitoringv1alpha1.Prometheus, host string) string {
+	return URL(p, host) + "/-/reload"
+}
+
+func prometheusArgs(p *monitoringv1alpha1.Prometheus) []string {
+	var args []string
+	if IsAgent(p) {
+		args = []string{"--config.file=/etc/config/prometheus.yml",
+			agentFlag(p),
+			"--storage.agent.path=/data",
+			"--web.enable-lifecycle",
+		}
+	} else {
+		args = []string{"--config.file=/etc/config/prometheus.yml",
+			"--storage.tsdb.path=/data",
+			"--web.enable-lifecycle",
+		}
+	}
+	args = append(args, webArgs(p)...)
+	if p.Spec.NativeHistograms {
+		args = append(args, "--enable-feature=native-histograms")
+	}
+	if p.Spec.Thanos != nil {
+		// Thanos uploads only the blocks compacted by Prometheus itself
+		args = append(args,
+			"--storage.tsdb.min-block-duration="+thanosBlockDuration,
+			"--storage.tsdb.max-block-duration="+thanosBlockDuration,
+		)
+	}
+	return args
+}
+
+// prometheusVolumeMounts returns the mounts of the prometheus container on top of its configuration and data.
+func prometheusVolumeMounts(p *monitoringv1alpha1.Prometheus) []corev1.VolumeMount {
+	m := webVolumeMounts(p)
+	m = append(m, scrapeSecretVolumeMounts(p)...)
+	return append(m, fileSDConfigMapVolumeMounts(p)...)
+}
+
 func prometheusContainer(p *monitoringv1alpha1.Prometheus) corev1.Container {
+	repository := prometheusRepository
+	if p.Spec.Image.Repository != nil {
+		repository = *p.Spec.Image.Repository
+	}
+
 	return corev1.Container{
 		Name:            "prometheus",
-		Image:           *p.Spec.Image.Repository + ":" + p.Spec.Image.Version,
-		ImagePullPolicy: corev1.PullIfNotPresent,
-		Args: []string{"--config.file=/etc/config/prometheus.yml",
-			"--storage.tsdb.path=/data",
-			"--web.enable-lifecycle",
-		},
-		Ports:     []corev1.ContainerPort{{ContainerPort: 9090}},
-		Resources: *p.Spec.Resources.DeepCopy(),
+		Image:           imageReference(repository, p.Spec.Image.Version, p.Spec.Image.Digest),
+		ImagePullPolicy: pullPolicy(p.Spec.ImagePullPolicy),
+		Args:            prometheusArgs(p),
+		Ports:           []corev1.ContainerPort{{ContainerPort: 9090}},
+		Resources:       *p.Spec.Resources.DeepCopy(),
+		SecurityContext: containerSecurityContext(p),
 		ReadinessProbe: &corev1.Probe{
-			ProbeHandler: corev1.ProbeHandler{
-				HTTPGet: &corev1.HTTPGetAction{
-					Path: "/-/ready",
-					Port: intstr.FromInt(prometheusPort),
-				},
-			},
+			ProbeHandler:        probeHandler(p, "/-/ready"),
 			InitialDelaySeconds: 30,
 			TimeoutSeconds:      30,
 		},
 		LivenessProbe: &corev1.Probe{
-			ProbeHandler: corev1.ProbeHandler{
-				HTTPGet: &corev1.HTTPGetAction{
-					Path: "/-/healthy",
-					Port: intstr.FromInt(prometheusPort),
-				},
-			},
+			ProbeHandler: probeHandler(p, "/-/healthy"),
 
 			InitialDelaySeconds: 30,
 			TimeoutSeconds:      30,
 		},
-		VolumeMounts: []corev1.VolumeMount{
+		VolumeMounts: append([]corev1.VolumeMount{
 			{
 				Name:      "targets-volume",
 				MountPath: "/etc/targets",
@@ -94,18 +255,18 @@ func prometheusContainer(p *monitoringv1alpha1.Prometheus) corev1.Container {
 				MountPath: "/data",
 				SubPath:   "",
 			},
-		},
+		}, prometheusVolumeMounts(p)...),
 	}
 }
 
-func volumes(n string) []corev1.Volume {
-	return []corev1.Volume{
+func volumes(p *monitoringv1alpha1.Prometheus, shard int32) []corev1.Volume {
+	v := []corev1.Volume{
 		{
 			Name: "config-volume",
 			VolumeSource: corev1.VolumeSource{
 				ConfigMap: &corev1.ConfigMapVolumeSource{
 					LocalObjectReference: corev1.LocalObjectReference{
-						Name: n + PrometheusConfigMapSuffix,
+						Name: ShardName(p, shard) + PrometheusConfigMapSuffix,
 					},
 				},
 			},
@@ -115,33 +276,62 @@ func volumes(n string) []corev1.Volume {
 			VolumeSource: corev1.VolumeSource{
 				ConfigMap: &corev1.ConfigMapVolumeSource{
 					LocalObjectReference: corev1.LocalObjectReference{
-						Name: n + PrometheusConfigMapTargetsSuffix,
+						Name: p.Name + PrometheusConfigMapTargetsSuffix,
 					},
 				},
 			},
 		},
 	}
+	v = append(v, webVolumes(p)...)
+	v = append(v, scrapeSecretVolumes(p)...)
+	v = append(v, fileSDConfigMapVolumes(p)...)
+	if p.Spec.EphemeralStorage {
+		v = append(v, corev1.Volume{
+			Name: p.Name,
+			VolumeSource: corev1.VolumeSource{
+				EmptyDir: &corev1.EmptyDirVolumeSource{},
+			},
+		})
+	}
+	return v
 }
 
-// func affinity(n string) corev1.Affinity {
-// 	return  corev1.PodAffinity{
-// 		RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{
-// 			corev1.PodAffinityTerm{
-// 				LabelSelector: []metav1.LabelSelectorRequirement{
-// 					metav1.LabelSelectorRequirement{
-// 						Key: "app.kubernetes.io/name",
-// 						Operator: metav1.LabelSelectorOpIn,
-// 						Values: []string{
-// 							"gs-prometheus",
-// 						},
-// 					},
-// 				},
-// 				TopologyKey: "kubernetes.io/hostname",
-// 			},
-// 		},
-// 	  }
+func affinity(p *monitoringv1alpha1.Prometheus, shard int32) *corev1.Affinity {
+	a := &corev1.Affinity{}
+	if p.Spec.Affinity != nil {
+		a = p.Spec.Affinity.DeepCopy()
+	}
+	if a.PodAntiAffinity == nil {
+		a.PodAntiAffinity = podAntiAffinity(p, shard)
+	}
+	return a
+}
+
+// podAntiAffinity spreads the replicas of a shard, the replicas of different shards may share a node.
+func podAntiAffinity(p *monitoringv1alpha1.Prometheus, shard int32) *corev1.PodAntiAffinity {
+	term := func(topologyKey string) corev1.PodAffinityTerm {
+		return corev1.PodAffinityTerm{
+			LabelSelector: &metav1.LabelSelector{
+				MatchLabels: shardLabels(p, shard),
+			},
+			TopologyKey: topologyKey,
+		}
+	}
 
-// }
+	zone := corev1.WeightedPodAffinityTerm{Weight: 50, PodAffinityTerm: term(corev1.LabelTopologyZone)}
+	if p.Spec.PodAntiAffinity == "hard" {
+		return &corev1.PodAntiAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution:  []corev1.PodAffinityTerm{term(corev1.LabelHostname)},
+			PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{zone},
+		}
+	}
+	return &corev1.PodAntiAffinity{
+		PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{
+			{Weight: 100, PodAffinityTerm: term(corev1.LabelHostname)},
+			zone,
+		},
+	}
+}
 
 func DesiredServiceAccount(p *monitoringv1alpha1.Prometheus) corev1.ServiceAccount {
 	return corev1.ServiceAccount{
@@ -150,7 +340,7 @@ func DesiredServiceAccount(p *monitoringv1alpha1.Prometheus) corev1.ServiceAccou
 }
 
 func DesiredClusterRole(p *monitoringv1alpha1.Prometheus) rbacv1.ClusterRole {
-	return rbacv1.ClusterRole{
+	cr := rbacv1.ClusterRole{
 		ObjectMeta: metav1.ObjectMeta{Name: p.Name, Namespace: p.Namespace, Labels: labels(p.Name)},
 		Rules: []rbacv1.PolicyRule{
 			rbacv1.PolicyRule{
@@ -169,6 +359,8 @@ func DesiredClusterRole(p *monitoringv1alpha1.Prometheus) rbacv1.ClusterRole {
 			},
 		},
 	}
+	cr.Rules = append(cr.Rules, kubernetesSDRules(p)...)
+	return cr
 }
 
 func DesiredClusterRoleBinding(p *monitoringv1alpha1.Prometheus) rbacv1.ClusterRoleBinding {
@@ -199,60 +391,191 @@ func volumeClaimTemplate(p *monitoringv1alpha1.Prometheus) corev1.PersistentVolu
 	return p.Spec.VolumeClaimTemplate
 }
 
-func DesiredStatefulSet(p *monitoringv1alpha1.Prometheus) appsv1.StatefulSet {
+func podContainers(p *monitoringv1alpha1.Prometheus) ([]corev1.Container, error) {
+	patches := make([]corev1.Container, 0, len(p.Spec.Containers)+1)
+	if len(p.Spec.VolumeMounts) > 0 {
+		patches = append(patches, corev1.Container{Name: "prometheus", VolumeMounts: p.Spec.VolumeMounts})
+	}
+	patches = append(patches, p.Spec.Containers...)
+	return mergeContainers(containers(p), patches)
+}
+
+func podTemplate(p *monitoringv1alpha1.Prometheus, shard int32) (corev1.PodTemplateSpec, error) {
+	podContainers, err := podContainers(p)
+	if err != nil {
+		return corev1.PodTemplateSpec{}, err
+	}
+	initContainers, err := mergeContainers(nil, p.Spec.InitContainers)
+	if err != nil {
+		return corev1.PodTemplateSpec{}, err
+	}
+	podVolumes := mergeVolumes(volumes(p, shard), p.Spec.Volumes)
+
+	return corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: shardLabels(p, shard),
+		},
+		Spec: corev1.PodSpec{
+			ServiceAccountName:        p.Name,
+			ImagePullSecrets:          p.Spec.ImagePullSecrets,
+			SecurityContext:           podSecurityContext(p),
+			InitContainers:            initContainers,
+			Containers:                podContainers,
+			Volumes:                   podVolumes,
+			NodeSelector:              p.Spec.NodeSelector,
+			Tolerations:               p.Spec.Tolerations,
+			Affinity:                  affinity(p, shard),
+			TopologySpreadConstraints: p.Spec.TopologySpreadConstraints,
+			PriorityClassName:         p.Spec.PriorityClassName,
+		},
+	}, nil
+}
+
+// DesiredStatefulSet returns the StatefulSet of a shard of the Prometheus.
+func DesiredStatefulSet(p *monitoringv1alpha1.Prometheus, shard int32) (appsv1.StatefulSet, error) {
+	template, err := podTemplate(p, shard)
+	if err != nil {
+		return appsv1.StatefulSet{}, err
+	}
+
+	var volumeClaimTemplates []corev1.PersistentVolumeClaim
+	if !p.Spec.EphemeralStorage {
+		volumeClaimTemplates = []corev1.PersistentVolumeClaim{volumeClaimTemplate(p)}
+	}
+
 	return appsv1.StatefulSet{
-		ObjectMeta: metav1.ObjectMeta{Name: p.Name, Namespace: p.Namespace, Labels: labels(p.Name)},
+		ObjectMeta: metav1.ObjectMeta{Name: ShardName(p, shard), Namespace: p.Namespace, Labels: shardLabels(p, shard)},
 		Spec: appsv1.StatefulSetSpec{
-			ServiceName:         p.Name,
+			ServiceName:         p.Name + GoverningServiceSuffix,
 			Replicas:            &p.Spec.Replicas,
 			UpdateStrategy:      appsv1.StatefulSetUpdateStrategy{Type: appsv1.RollingUpdateStatefulSetStrategyType},
 			PodManagementPolicy: appsv1.ParallelPodManagement,
 			Selector: &metav1.LabelSelector{
-				MatchLabels: labels(p.Name),
-			},
-			VolumeClaimTemplates: []corev1.PersistentVolumeClaim{volumeClaimTemplate(p)},
-			Template: corev1.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{
-					Labels: labels(p.Name),
-				},
-				Spec: corev1.PodSpec{
-					ServiceAccountName: p.Name,
-					Containers: []corev1.Container{
-						sidecarContainer(),
-						prometheusContainer(p),
-					},
-					Volumes: volumes(p.Name),
-					// Affinity:                      affinity(),
-				},
+				MatchLabels: shardLabels(p, shard),
 			},
+			VolumeClaimTemplates: volumeClaimTemplates,
+			Template:             template,
 		},
+	}, nil
+}
+
+// DesiredDeployment returns the Deployment of a shard of a Prometheus agent without persistent storage.
+func DesiredDeployment(p *monitoringv1alpha1.Prometheus, shard int32) (appsv1.Deployment, error) {
+	template, err := podTemplate(p, shard)
+	if err != nil {
+		return appsv1.Deployment{}, err
 	}
+
+	return appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: ShardName(p, shard), Namespace: p.Namespace, Labels: shardLabels(p, shard)},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &p.Spec.Replicas,
+			Strategy: appsv1.DeploymentStrategy{Type: appsv1.RollingUpdateDeploymentStrategyType},
+			Selector: &metav1.LabelSelector{
+				MatchLabels: shardLabels(p, shard),
+			},
+			Template: template,
+		},
+	}, nil
 }
 
-func DesiredService(p *monitoringv1alpha1.Prometheus) corev1.Service {
-	return corev1.Service{
-		ObjectMeta: metav1.ObjectMeta{Name: p.Name, Namespace: p.Namespace, Labels: labels(p.Name)},
-		Spec: corev1.ServiceSpec{
-			Ports: []corev1.ServicePort{
-				corev1.ServicePort{
-					Name:       "http",
-					Port:       prometheusPort,
-					Protocol:   "TCP",
-					TargetPort: intstr.FromInt(prometheusPort),
-				},
+// DesiredPodDisruptionBudget returns the PodDisruptionBudget of the replicas of a shard, so
+// a disruption cannot evict a whole shard, and false when no budget should exist.
+func DesiredPodDisruptionBudget(p *monitoringv1alpha1.Prometheus, shard int32) (policyv1.PodDisruptionBudget, bool) {
+	spec := p.Spec.PodDisruptionBudget
+	if spec == nil {
+		spec = &monitoringv1alpha1.PodDisruptionBudgetSpec{}
+	}
+	if spec.Enabled != nil && !*spec.Enabled {
+		return policyv1.PodDisruptionBudget{}, false
+	}
+	if spec.Enabled == nil && p.Spec.Replicas <= 1 {
+		return policyv1.PodDisruptionBudget{}, false
+	}
+
+	pdb := policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Name: ShardName(p, shard), Namespace: p.Namespace, Labels: shardLabels(p, shard)},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: shardLabels(p, shard),
 			},
-			SessionAffinity: corev1.ServiceAffinityClientIP,
-			Selector:        labels(p.Name),
 		},
 	}
+	switch {
+	case spec.MinAvailable != nil:
+		pdb.Spec.MinAvailable = spec.MinAvailable
+	case spec.MaxUnavailable != nil:
+		pdb.Spec.MaxUnavailable = spec.MaxUnavailable
+	default:
+		maxUnavailable := intstr.FromInt(1)
+		pdb.Spec.MaxUnavailable = &maxUnavailable
+	}
+	return pdb, true
 }
 
-func DesiredPrometheusConfigMap(p *monitoringv1alpha1.Prometheus) (corev1.ConfigMap, error) {
+// scrapeConfigs returns the scrape jobs of the Prometheus, before sharding.
+func scrapeConfigs(p *monitoringv1alpha1.Prometheus, tg TargetGroups) []PrometheusScrapeConfig {
+	configs := getPrometheusScrapeConfig(p.Spec.AdditionalScrapeConfig, scrapeTargetNamespaceRelabelConfigs(p))
+	for i, sc := range p.Spec.AdditionalScrapeConfig {
+		if sc.Federate != nil {
+			applyFederation(&configs[i], sc, tg)
+		}
+	}
+	configs = append(configs, fileSDScrapeConfigs(p, tg)...)
+	configs = append(configs, fileSDConfigMapScrapeConfigs(p, tg)...)
+	configs = append(configs, probeScrapeConfigs(p, tg)...)
+	if p.Spec.SelfMonitor {
+		configs = append(configs, selfScrapeConfig(p))
+	}
+	configs = append(configs, presetScrapeConfigs(p)...)
+	return configs
+}
+
+// JobCount returns the number of scrape jobs of the generated configuration.
+func JobCount(p *monitoringv1alpha1.Prometheus, tg TargetGroups) int {
+	return len(scrapeConfigs(p, tg))
+}
+
+// StaticTargetCount returns the number of targets listed in the spec and the target groups,
+// discovered targets not being known to the operator.
+func StaticTargetCount(p *monitoringv1alpha1.Prometheus, tg TargetGroups) int {
+	count := tg.TargetCount()
+	for _, sc := range p.Spec.AdditionalScrapeConfig {
+		for _, static := range sc.StaticConfigs {
+			count += len(static.Targets)
+		}
+	}
+	return count
+}
+
+// DesiredPrometheusConfigMap returns the Prometheus configuration of a shard of the Prometheus.
+func DesiredPrometheusConfigMap(p *monitoringv1alpha1.Prometheus, shard int32, tg TargetGroups) (corev1.ConfigMap, error) {
 
 	cfg := PrometheusConfigFile{
-		ScrapeConfigs: getPrometheusScrapeConfig(p.Spec.AdditionalScrapeConfig),
+		ScrapeConfigFiles: p.Spec.ScrapeConfigFiles,
+		ScrapeConfigs:     scrapeConfigs(p, tg),
 	}
 
+	externalLabels := make(map[string]string, len(p.Spec.ExternalLabels)+1)
+	for k, v := range p.Spec.ExternalLabels {
+		externalLabels[k] = v
+	}
+	if shards := Shards(p); shards > 1 {
+		for i := range cfg.ScrapeConfigs {
+			// Every shard monitors all the replicas
+			if p.Spec.SelfMonitor && cfg.ScrapeConfigs[i].JobName == selfMonitorJobName {
+				continue
+			}
+			source := shardSourceLabel(cfg.ScrapeConfigs[i].JobName, tg)
+			cfg.ScrapeConfigs[i].RelabelConfigs = append(cfg.ScrapeConfigs[i].RelabelConfigs, shardRelabelConfigs(source, shard, shards)...)
+		}
+		externalLabels[shardExternalLabel] = strconv.Itoa(int(shard))
+	}
+	if len(externalLabels) > 0 {
+		cfg.Global = &GlobalConfig{ExternalLabels: externalLabels}
+	}
+	cfg.RemoteWrite = getRemoteWriteConfig(p.Spec.RemoteWrite)
+
 	yamlData, err := yaml.Marshal(&cfg)
 
 	if err != nil {
@@ -260,21 +583,17 @@ func DesiredPrometheusConfigMap(p *monitoringv1alpha1.Prometheus) (corev1.Config
 	}
 
 	return corev1.ConfigMap{
-		ObjectMeta: metav1.ObjectMeta{Name: p.Name + PrometheusConfigMapSuffix, Namespace: p.Namespace, Labels: labels(p.Name)},
+		ObjectMeta: metav1.ObjectMeta{Name: ShardName(p, shard) + PrometheusConfigMapSuffix, Namespace: p.Namespace, Labels: shardLabels(p, shard)},
 		Data: map[string]string{
-			"prometheus.yml": string(yamlData),
+			PrometheusConfigKey: string(yamlData),
 		},
 	}, nil
 }
 
-func DesiredTargetsConfigMap(p *monitoringv1alpha1.Prometheus) (corev1.ConfigMap, error) {
-
-	str, err := yaml.Marshal(p.Spec.Targets)
+func DesiredTargetsConfigMap(p *monitoringv1alpha1.Prometheus, tg TargetGroups) (corev1.ConfigMap, error) {
+	data, err := targetsData(tg)
 	if err != nil {
-		return corev1.ConfigMap{}, fmt.Errorf("unable to Marshal 'targets', %v", err)
-	}
-	data := map[string]string{
-		"targets.yaml": string(str),
+		return corev1.ConfigMap{}, err
 	}
 
 	return corev1.ConfigMap{
@@ -282,3 +601,34 @@ func DesiredTargetsConfigMap(p *monitoringv1alpha1.Prometheus) (corev1.ConfigMap
 		Data:       data,
 	}, nil
 }
+
+// ConfigHash returns a hash of the Prometheus configuration and targets,
+// changing whenever one of the ConfigMaps needs to be reloaded.
+func ConfigHash(p *monitoringv1alpha1.Prometheus, tg TargetGroups) (string, error) {
+	h := sha256.New()
+	cms := make([]corev1.ConfigMap, 0, Shards(p)+1)
+	for shard := int32(0); shard < Shards(p); shard++ {
+		cm, err := DesiredPrometheusConfigMap(p, shard, tg)
+		if err != nil {
+			return "", err
+		}
+		cms = append(cms, cm)
+	}
+	tcm, err := DesiredTargetsConfigMap(p, tg)
+	if err != nil {
+		return "", err
+	}
+	cms = append(cms, tcm)
+
+	for _, cm := range cms {
+		keys := make([]string, 0, len(cm.Data))
+		for k := range cm.Data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(h, "%s/%s\n%s\n", cm.Name, k, cm.Data[k])
+		}
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
@@ -3,31 +3,126 @@ package controllers
 import monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
 
 type PrometheusConfigFile struct {
-	ScrapeConfigs []PrometheusScrapeConfig `yaml:"scrape_configs"`
+	Global            *GlobalConfig            `yaml:"global,omitempty"`
+	ScrapeConfigFiles []string                 `yaml:"scrape_config_files,omitempty"`
+	ScrapeConfigs     []PrometheusScrapeConfig `yaml:"scrape_configs"`
+	RemoteWrite       []RemoteWriteConfig      `yaml:"remote_write,omitempty"`
+}
+
+type GlobalConfig struct {
+	ExternalLabels map[string]string `yaml:"external_labels,omitempty"`
 }
 
 type PrometheusScrapeConfig struct {
-	JobName string `yaml:"job_name"`
+	JobName     string `yaml:"job_name"`
+	HonorLabels bool   `yaml:"honor_labels,omitempty"`
+
+	ScrapeInterval      string                   `yaml:"scrape_interval,omitempty"`
+	ScrapeTimeout       string                   `yaml:"scrape_timeout,omitempty"`
+	Scheme              string                   `yaml:"scheme,omitempty"`
+	MetricsPath         string                   `yaml:"metrics_path,omitempty"`
+	Params              map[string][]string      `yaml:"params,omitempty"`
+	TlsConfig           TLSConfig                `yaml:"tls_config,omitempty"`
+	BearerTokenFile     string                   `yaml:"bearer_token_file,omitempty"`
+	BasicAuth           *BasicAuth               `yaml:"basic_auth,omitempty"`
+	KeepDroppedTargets  *int32                   `yaml:"keep_dropped_targets,omitempty"`
+	StaticConfigs       []StaticConfig           `yaml:"static_configs,omitempty"`
+	FileSdConfigs       []PrometheusFileSdConfig `yaml:"file_sd_configs,omitempty"`
+	DNSSDConfigs        []DNSSDConfig            `yaml:"dns_sd_configs,omitempty"`
+	KubernetesSDConfigs []KubernetesSDConfig     `yaml:"kubernetes_sd_configs,omitempty"`
+	HTTPSDConfigs       []HTTPSDConfig           `yaml:"http_sd_configs,omitempty"`
+	ConsulSDConfigs     []ConsulSDConfig         `yaml:"consul_sd_configs,omitempty"`
+	RelabelConfigs      []RelabelConfig          `yaml:"relabel_configs,omitempty"`
+}
+
+type RelabelConfig struct {
+	SourceLabels []string `yaml:"source_labels,flow,omitempty"`
+	Separator    string   `yaml:"separator,omitempty"`
+	Regex        string   `yaml:"regex,omitempty"`
+	Modulus      uint64   `yaml:"modulus,omitempty"`
+	TargetLabel  string   `yaml:"target_label,omitempty"`
+	Replacement  string   `yaml:"replacement,omitempty"`
+	Action       string   `yaml:"action,omitempty"`
+}
+
+type RemoteWriteConfig struct {
+	URL             string     `yaml:"url"`
+	Name            string     `yaml:"name,omitempty"`
+	RemoteTimeout   string     `yaml:"remote_timeout,omitempty"`
+	TlsConfig       *TLSConfig `yaml:"tls_config,omitempty"`
+	BearerTokenFile string     `yaml:"bearer_token_file,omitempty"`
+}
+
+type BasicAuth struct {
+	Username     string `yaml:"username"`
+	PasswordFile string `yaml:"password_file,omitempty"`
+}
+
+type DNSSDConfig struct {
+	Names           []string `yaml:"names"`
+	RefreshInterval string   `yaml:"refresh_interval,omitempty"`
+	Type            string   `yaml:"type,omitempty"`
+	Port            int      `yaml:"port,omitempty"`
+}
+
+type HTTPSDConfig struct {
+	URL             string     `yaml:"url"`
+	RefreshInterval string     `yaml:"refresh_interval,omitempty"`
+	BasicAuth       *BasicAuth `yaml:"basic_auth,omitempty"`
+	BearerTokenFile string     `yaml:"bearer_token_file,omitempty"`
+	TlsConfig       *TLSConfig `yaml:"tls_config,omitempty"`
+}
+
+type ConsulSDConfig struct {
+	Server          string            `yaml:"server,omitempty"`
+	Scheme          string            `yaml:"scheme,omitempty"`
+	Datacenter      string            `yaml:"datacenter,omitempty"`
+	Services        []string          `yaml:"services,omitempty"`
+	Tags            []string          `yaml:"tags,omitempty"`
+	NodeMeta        map[string]string `yaml:"node_meta,omitempty"`
+	AllowStale      *bool             `yaml:"allow_stale,omitempty"`
+	RefreshInterval string            `yaml:"refresh_interval,omitempty"`
+	TlsConfig       *TLSConfig        `yaml:"tls_config,omitempty"`
+}
+
+type KubernetesSDConfig struct {
+	Role           string                    `yaml:"role"`
+	Namespaces     *KubernetesNamespaces     `yaml:"namespaces,omitempty"`
+	Selectors      []KubernetesSelector      `yaml:"selectors,omitempty"`
+	AttachMetadata *KubernetesAttachMetadata `yaml:"attach_metadata,omitempty"`
+}
+
+type KubernetesNamespaces struct {
+	Names []string `yaml:"names"`
+}
+
+type KubernetesSelector struct {
+	Role  string `yaml:"role"`
+	Label string `yaml:"label,omitempty"`
+	Field string `yaml:"field,omitempty"`
+}
 
-	Scheme          string                   `yaml:"scheme,omitempty"`
-	TlsConfig       TLSConfig                `yaml:"tls_config,omitempty"`
-	BearerTokenFile string                   `yaml:"bearer_token_file,omitempty"`
-	StaticConfigs   []StaticConfig           `yaml:"static_configs,omitempty"`
-	FileSdConfigs   []PrometheusFileSdConfig `yaml:"file_sd_configs,omitempty"`
+type KubernetesAttachMetadata struct {
+	Node bool `yaml:"node"`
 }
 
 type StaticConfig struct {
-	Targets []string `yaml:"targets"`
+	Targets []string          `yaml:"targets"`
+	Labels  map[string]string `yaml:"labels,omitempty"`
 }
 
 type TLSConfig struct {
-	InsecureSkipVerify bool `yaml:"insecure_skip_verify"`
+	CAFile             string `yaml:"ca_file,omitempty"`
+	ServerName         string `yaml:"server_name,omitempty"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
 }
 type PrometheusFileSdConfig struct {
 	Files []string `yaml:"files"`
 }
 
-func getPrometheusScrapeConfig(s []monitoringv1alpha1.ScrapeConfig) []PrometheusScrapeConfig {
+// getPrometheusScrapeConfig returns the jobs of the scrape configs followed by the legacy job,
+// which reads the target groups and is relabeled with targetRelabelConfigs.
+func getPrometheusScrapeConfig(s []monitoringv1alpha1.ScrapeConfig, targetRelabelConfigs []RelabelConfig) []PrometheusScrapeConfig {
 	r := make([]PrometheusScrapeConfig, 0)
 
 	if s != nil {
@@ -39,26 +134,55 @@ func getPrometheusScrapeConfig(s []monitoringv1alpha1.ScrapeConfig) []Prometheus
 					Targets: sc.Targets})
 			}
 			psc := PrometheusScrapeConfig{
-				JobName:         i.JobName,
-				Scheme:          i.Scheme,
-				TlsConfig:       TLSConfig{InsecureSkipVerify: i.TlsConfig.InsecureSkipVerify},
-				BearerTokenFile: i.BearerTokenFile,
-				StaticConfigs:   ts,
+				JobName:             i.JobName,
+				Scheme:              i.Scheme,
+				TlsConfig:           *tlsConfig(&i.TlsConfig),
+				BearerTokenFile:     bearerTokenFile(i),
+				KeepDroppedTargets:  i.KeepDroppedTargets,
+				StaticConfigs:       ts,
+				KubernetesSDConfigs: kubernetesSDConfigs(i),
+				DNSSDConfigs:        dnsSDConfigs(i),
+				HTTPSDConfigs:       httpSDConfigs(i),
+				ConsulSDConfigs:     consulSDConfigs(i),
 			}
 			r = append(r, psc)
 		}
 	}
 
 	r = append(r, PrometheusScrapeConfig{
-		JobName: "gs",
+		JobName: legacyJobName,
 		FileSdConfigs: []PrometheusFileSdConfig{
 			PrometheusFileSdConfig{
 				Files: []string{
-					"/etc/targets/targets.yaml",
+					targetsDir + "/" + legacyTargetsFile,
 				},
 			},
 		},
+		RelabelConfigs: targetRelabelConfigs,
 	})
 
 	return r
 }
+
+// tlsConfig converts the TLS settings of the spec, nil when there are none.
+func tlsConfig(t *monitoringv1alpha1.TLSConfig) *TLSConfig {
+	if t == nil {
+		return nil
+	}
+	return &TLSConfig{InsecureSkipVerify: t.InsecureSkipVerify}
+}
+
+func getRemoteWriteConfig(s []monitoringv1alpha1.RemoteWriteSpec) []RemoteWriteConfig {
+	r := make([]RemoteWriteConfig, 0, len(s))
+	for _, i := range s {
+		rw := RemoteWriteConfig{
+			URL:             i.URL,
+			Name:            i.Name,
+			RemoteTimeout:   i.RemoteTimeout,
+			BearerTokenFile: i.BearerTokenFile,
+			TlsConfig:       tlsConfig(i.TlsConfig),
+		}
+		r = append(r, rw)
+	}
+	return r
+}
@@ -0,0 +1,174 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestAffinity(t *testing.T) {
+	p := newTestPrometheus("v2.47.0")
+	a := affinity(p, 0)
+	preferred := a.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution
+	if len(a.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution) != 0 || len(preferred) != 2 ||
+		preferred[0].PodAffinityTerm.TopologyKey != corev1.LabelHostname || preferred[1].PodAffinityTerm.TopologyKey != corev1.LabelTopologyZone {
+		t.Errorf("unexpected soft pod anti-affinity: %+v", a.PodAntiAffinity)
+	}
+	if diff := cmp.Diff(shardLabels(p, 0), preferred[0].PodAffinityTerm.LabelSelector.MatchLabels); diff != "" {
+		t.Errorf("unexpected pod anti-affinity selector (-want +got):\n%s", diff)
+	}
+	second := affinity(p, 1).PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution[0].PodAffinityTerm.LabelSelector
+	if diff := cmp.Diff(shardLabels(p, 1), second.MatchLabels); diff != "" {
+		t.Errorf("unexpected pod anti-affinity selector of the second shard (-want +got):\n%s", diff)
+	}
+
+	p.Spec.PodAntiAffinity = "hard"
+	a = affinity(p, 0)
+	required := a.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+	if len(required) != 1 || required[0].TopologyKey != corev1.LabelHostname || len(a.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution) != 1 {
+		t.Errorf("unexpected hard pod anti-affinity: %+v", a.PodAntiAffinity)
+	}
+
+	nodeAffinity := &corev1.NodeAffinity{RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{}}
+	antiAffinity := &corev1.PodAntiAffinity{}
+	p.Spec.Affinity = &corev1.Affinity{NodeAffinity: nodeAffinity, PodAntiAffinity: antiAffinity}
+	a = affinity(p, 0)
+	if diff := cmp.Diff(&corev1.Affinity{NodeAffinity: nodeAffinity, PodAntiAffinity: antiAffinity}, a); diff != "" {
+		t.Errorf("affinity of the spec not used as is (-want +got):\n%s", diff)
+	}
+	if a.NodeAffinity == nodeAffinity {
+		t.Error("affinity of the spec not copied")
+	}
+}
+
+func TestConfigHash(t *testing.T) {
+	p := newTestPrometheus("v2.47.0")
+	hash := func(p *monitoringv1alpha1.Prometheus) string {
+		h, err := ConfigHash(p, SpecTargetGroups(p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+	base := hash(p)
+	if again := hash(p); again != base {
+		t.Errorf("hash is not stable: %v != %v", again, base)
+	}
+
+	p.Spec.Targets = []monitoringv1alpha1.PrometheusTarget{{Targets: []string{"node:9100"}}}
+	targets := hash(p)
+	if targets == base {
+		t.Error("hash unchanged by the targets")
+	}
+	p.Spec.ExternalLabels = map[string]string{"cluster": "test"}
+	if hash(p) == targets {
+		t.Error("hash unchanged by the configuration")
+	}
+}
+
+func TestDesiredDeployment(t *testing.T) {
+	p := newTestPrometheus("v2.47.0")
+	p.Spec.Mode = monitoringv1alpha1.ModeAgent
+	p.Spec.EphemeralStorage = true
+	p.Spec.Replicas = 2
+	if !UsesDeployment(p) {
+		t.Fatal("agent with ephemeral storage does not use a Deployment")
+	}
+
+	d, err := DesiredDeployment(p, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Name != ShardName(p, 0) || *d.Spec.Replicas != 2 {
+		t.Errorf("unexpected Deployment %v with %v replicas", d.Name, *d.Spec.Replicas)
+	}
+	if diff := cmp.Diff(shardLabels(p, 0), d.Spec.Selector.MatchLabels); diff != "" {
+		t.Errorf("unexpected selector (-want +got):\n%s", diff)
+	}
+	var args string
+	for _, c := range d.Spec.Template.Spec.Containers {
+		if c.Name == "prometheus" {
+			args = strings.Join(c.Args, " ")
+		}
+	}
+	if !strings.Contains(args, agentFlag(p)) || !strings.Contains(args, "--storage.agent.path=/data") {
+		t.Errorf("prometheus not run as an agent: %v", args)
+	}
+	var data *corev1.Volume
+	for i, v := range d.Spec.Template.Spec.Volumes {
+		if v.Name == p.Name {
+			data = &d.Spec.Template.Spec.Volumes[i]
+		}
+	}
+	if data == nil || data.EmptyDir == nil {
+		t.Errorf("data not stored in an emptyDir volume: %+v", d.Spec.Template.Spec.Volumes)
+	}
+
+	p.Spec.EphemeralStorage = false
+	if UsesDeployment(p) {
+		t.Error("agent with persistent storage uses a Deployment")
+	}
+}
+
+func TestDesiredPodDisruptionBudget(t *testing.T) {
+	enabled, disabled := true, false
+	one, half := intstr.FromInt(1), intstr.FromString("50%")
+	tests := []struct {
+		name               string
+		replicas           int32
+		spec               *monitoringv1alpha1.PodDisruptionBudgetSpec
+		wantNeeded         bool
+		wantMinAvailable   *intstr.IntOrString
+		wantMaxUnavailable *intstr.IntOrString
+	}{
+		{name: "single replica", replicas: 1},
+		{name: "replicas", replicas: 2, wantNeeded: true, wantMaxUnavailable: &one},
+		{name: "enabled for a single replica", replicas: 1, spec: &monitoringv1alpha1.PodDisruptionBudgetSpec{Enabled: &enabled}, wantNeeded: true, wantMaxUnavailable: &one},
+		{name: "disabled", replicas: 3, spec: &monitoringv1alpha1.PodDisruptionBudgetSpec{Enabled: &disabled}},
+		{name: "minAvailable", replicas: 3, spec: &monitoringv1alpha1.PodDisruptionBudgetSpec{MinAvailable: &half}, wantNeeded: true, wantMinAvailable: &half},
+		{name: "maxUnavailable", replicas: 3, spec: &monitoringv1alpha1.PodDisruptionBudgetSpec{MaxUnavailable: &half}, wantNeeded: true, wantMaxUnavailable: &half},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPrometheus("v2.47.0")
+			p.Spec.Replicas = tt.replicas
+			p.Spec.PodDisruptionBudget = tt.spec
+
+			pdb, needed := DesiredPodDisruptionBudget(p, 0)
+			if needed != tt.wantNeeded {
+				t.Fatalf("needed = %v, want %v", needed, tt.wantNeeded)
+			}
+			if !needed {
+				return
+			}
+			if diff := cmp.Diff(tt.wantMinAvailable, pdb.Spec.MinAvailable); diff != "" {
+				t.Errorf("unexpected minAvailable (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantMaxUnavailable, pdb.Spec.MaxUnavailable); diff != "" {
+				t.Errorf("unexpected maxUnavailable (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestShardPodDisruptionBudget(t *testing.T) {
+	p := newTestShardedPrometheus(2)
+	p.Spec.Replicas = 2
+
+	for shard := int32(0); shard < 2; shard++ {
+		pdb, needed := DesiredPodDisruptionBudget(p, shard)
+		if !needed {
+			t.Fatalf("no PodDisruptionBudget for shard %d", shard)
+		}
+		if pdb.Name != ShardName(p, shard) {
+			t.Errorf("unexpected name %v of the budget of shard %d", pdb.Name, shard)
+		}
+		if diff := cmp.Diff(shardLabels(p, shard), pdb.Spec.Selector.MatchLabels); diff != "" {
+			t.Errorf("unexpected selector of shard %d (-want +got):\n%s", shard, diff)
+		}
+	}
+}
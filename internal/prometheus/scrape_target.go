@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"sort"
+
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8slabels "k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// ScrapeTargetNamespaceLabel target label holding the namespace of the ScrapeTarget
+	// defining the target, available to relabeling.
+	ScrapeTargetNamespaceLabel = "__meta_scrapetarget_namespace"
+	// ScrapeTargetNameLabel target label holding the name of the ScrapeTarget defining the target.
+	ScrapeTargetNameLabel = "__meta_scrapetarget_name"
+)
+
+// TargetGroups are the target groups of a Prometheus, merged from its spec and the selected ScrapeTargets.
+type TargetGroups struct {
+	// Groups rendered into the targets ConfigMap
+	Groups []monitoringv1alpha1.PrometheusTarget
+	// Selected ScrapeTargets, sorted by namespace and name
+	Selected []types.NamespacedName
+	// Conflicts targets of the selected ScrapeTargets left out as already defined
+	Conflicts map[types.NamespacedName][]string
+	// FileSDKeys keys of each FileSDConfigMap holding valid target groups, by ConfigMap name
+	FileSDKeys map[string][]string
+	// FileSDErrors validation errors of the FileSDConfigMap keys left out
+	FileSDErrors []error
+	// Probes selected and valid, sorted by namespace and name
+	Probes []monitoringv1alpha1.Probe
+	// ProbeErrors validation errors of the selected Probes left out
+	ProbeErrors []error
+	// Federation endpoints of the federation jobs, in the order of the jobs
+	Federation []monitoringv1alpha1.FederationStatus
+}
+
+// SpecTargetGroups returns the target groups of the Prometheus spec only.
+func SpecTargetGroups(p *monitoringv1alpha1.Prometheus) TargetGroups {
+	return TargetGroups{Groups: p.Spec.Targets}
+}
+
+// SelectsScrapeTarget returns whether the ScrapeTargetSelector of the Prometheus selects the ScrapeTarget.
+func SelectsScrapeTarget(p *monitoringv1alpha1.Prometheus, st *monitoringv1alpha1.ScrapeTarget) (bool, error) {
+	if p.Spec.ScrapeTargetSelector == nil {
+		return false, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(p.Spec.ScrapeTargetSelector)
+	if err != nil {
+		return false, err
+	}
+	return selector.Matches(k8slabels.Set(st.Labels)), nil
+}
+
+// MergeTargetGroups merges the target groups of the selected ScrapeTargets into the ones of the
+// Prometheus spec. A target is only kept the first time it is defined in a job, the spec coming
+// first and the ScrapeTargets following in namespace and name order. Groups of a job reserved
+// to the spec, or setting scrape options conflicting with the ones of their job, are left out.
+func MergeTargetGroups(p *monitoringv1alpha1.Prometheus, scrapeTargets []monitoringv1alpha1.ScrapeTarget) (TargetGroups, error) {
+	tg := SpecTargetGroups(p)
+	tg.Groups = append([]monitoringv1alpha1.PrometheusTarget{}, tg.Groups...)
+	tg.Conflicts = map[types.NamespacedName][]string{}
+
+	// A target is identified by its address within a job
+	seen := map[string]bool{}
+	options := map[string]monitoringv1alpha1.PrometheusTarget{}
+	for _, group := range p.Spec.Targets {
+		for _, target := range group.Targets {
+			seen[group.JobName+"/"+target] = true
+		}
+		options[group.JobName] = mergeScrapeOptions([]monitoringv1alpha1.PrometheusTarget{options[group.JobName], group})
+	}
+	reserved := reservedJobNames(p)
+
+	var selected []monitoringv1alpha1.ScrapeTarget
+	for i := range scrapeTargets {
+		ok, err := SelectsScrapeTarget(p, &scrapeTargets[i])
+		if err != nil {
+			return TargetGroups{}, err
+		}
+		if ok {
+			selected = append(selected, scrapeTargets[i])
+		}
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		if selected[i].Namespace != selected[j].Namespace {
+			return selected[i].Namespace < selected[j].Namespace
+		}
+		return selected[i].Name < selected[j].Name
+	})
+
+	for _, st := range selected {
+		nn := types.NamespacedName{Namespace: st.Namespace, Name: st.Name}
+		tg.Selected = append(tg.Selected, nn)
+		for _, group := range st.Spec.TargetGroups {
+			conflicting := reserved[group.JobName] || (group.JobName != "" && scrapeOptionsConflict(options[group.JobName], group))
+			var targets []string
+			for _, target := range group.Targets {
+				if conflicting || seen[group.JobName+"/"+target] {
+					tg.Conflicts[nn] = append(tg.Conflicts[nn], target)
+					continue
+				}
+				seen[group.JobName+"/"+target] = true
+				targets = append(targets, target)
+			}
+			if len(targets) == 0 {
+				continue
+			}
+			options[group.JobName] = mergeScrapeOptions([]monitoringv1alpha1.PrometheusTarget{options[group.JobName], group})
+
+			groupLabels := make(map[string]string, len(group.Labels)+2)
+			for k, v := range group.Labels {
+				groupLabels[k] = v
+			}
+			groupLabels[ScrapeTargetNamespaceLabel] = st.Namespace
+			groupLabels[ScrapeTargetNameLabel] = st.Name
+			merged := *group.DeepCopy()
+			merged.Targets = targets
+			merged.Labels = groupLabels
+			tg.Groups = append(tg.Groups, merged)
+		}
+	}
+	return tg, nil
+}
+
+// TargetCount returns the number of targets of the target groups.
+func (tg TargetGroups) TargetCount() int {
+	count := 0
+	for _, group := range tg.Groups {
+		count += len(group.Targets)
+	}
+	return count
+}
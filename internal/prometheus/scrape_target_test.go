@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestMergeTargetGroups(t *testing.T) {
+	p := newTestPrometheus("v2.47.0")
+	p.Spec.Targets = []monitoringv1alpha1.PrometheusTarget{{Targets: []string{"a:80"}}}
+	p.Spec.ScrapeTargetSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}}
+
+	scrapeTarget := func(namespace, team string, targets ...string) monitoringv1alpha1.ScrapeTarget {
+		return monitoringv1alpha1.ScrapeTarget{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "targets", Labels: map[string]string{"team": team}},
+			Spec: monitoringv1alpha1.ScrapeTargetSpec{
+				TargetGroups: []monitoringv1alpha1.PrometheusTarget{{Targets: targets, Labels: map[string]string{"app": namespace}}},
+			},
+		}
+	}
+	tg, err := MergeTargetGroups(p, []monitoringv1alpha1.ScrapeTarget{
+		scrapeTarget("ns2", "a", "b:80", "c:80"),
+		scrapeTarget("ns1", "a", "a:80", "b:80"),
+		scrapeTarget("ns3", "b", "d:80"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ns1 := types.NamespacedName{Namespace: "ns1", Name: "targets"}
+	ns2 := types.NamespacedName{Namespace: "ns2", Name: "targets"}
+	want := TargetGroups{
+		Groups: []monitoringv1alpha1.PrometheusTarget{
+			{Targets: []string{"a:80"}},
+			{Targets: []string{"b:80"}, Labels: map[string]string{"app": "ns1", ScrapeTargetNamespaceLabel: "ns1", ScrapeTargetNameLabel: "targets"}},
+			{Targets: []string{"c:80"}, Labels: map[string]string{"app": "ns2", ScrapeTargetNamespaceLabel: "ns2", ScrapeTargetNameLabel: "targets"}},
+		},
+		Selected:  []types.NamespacedName{ns1, ns2},
+		Conflicts: map[types.NamespacedName][]string{ns1: {"a:80"}, ns2: {"b:80"}},
+	}
+	if diff := cmp.Diff(want, tg); diff != "" {
+		t.Errorf("unexpected target groups (-want +got):\n%s", diff)
+	}
+}
+
+func TestMergeTargetGroupsScrapeOptions(t *testing.T) {
+	p := newTestPrometheus("v2.47.0")
+	p.Spec.Targets = []monitoringv1alpha1.PrometheusTarget{{Targets: []string{"a:80"}, JobName: "web", ScrapeInterval: "30s"}}
+	p.Spec.ScrapeTargetSelector = &metav1.LabelSelector{}
+
+	scrapeTarget := func(name string, group monitoringv1alpha1.PrometheusTarget) monitoringv1alpha1.ScrapeTarget {
+		return monitoringv1alpha1.ScrapeTarget{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: name},
+			Spec:       monitoringv1alpha1.ScrapeTargetSpec{TargetGroups: []monitoringv1alpha1.PrometheusTarget{group}},
+		}
+	}
+	tg, err := MergeTargetGroups(p, []monitoringv1alpha1.ScrapeTarget{
+		scrapeTarget("a", monitoringv1alpha1.PrometheusTarget{Targets: []string{"b:80"}, JobName: "web", Scheme: "https"}),
+		scrapeTarget("b", monitoringv1alpha1.PrometheusTarget{Targets: []string{"c:80"}, JobName: "web", ScrapeInterval: "1m"}),
+		scrapeTarget("c", monitoringv1alpha1.PrometheusTarget{Targets: []string{"d:80"}, JobName: "web", Scheme: "http"}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := types.NamespacedName{Namespace: "team-a", Name: "b"}
+	c := types.NamespacedName{Namespace: "team-a", Name: "c"}
+	if diff := cmp.Diff(map[types.NamespacedName][]string{b: {"c:80"}, c: {"d:80"}}, tg.Conflicts); diff != "" {
+		t.Errorf("unexpected conflicts (-want +got):\n%s", diff)
+	}
+	configs := fileSDScrapeConfigs(p, tg)
+	if len(configs) != 1 || configs[0].ScrapeInterval != "30s" || configs[0].Scheme != "https" {
+		t.Errorf("unexpected scrape configs: %+v", configs)
+	}
+}
@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+)
+
+const selfMonitorJobName = "prometheus"
+
+// selfScrapeConfig returns the job scraping every replica of the Prometheus, resolved through
+// the governing Service. Replicas of a Deployment only scrape themselves.
+func selfScrapeConfig(p *monitoringv1alpha1.Prometheus) PrometheusScrapeConfig {
+	sc := PrometheusScrapeConfig{
+		JobName:     selfMonitorJobName,
+		Scheme:      strings.ToLower(string(webScheme(p))),
+		MetricsPath: routePrefix(p) + "/metrics",
+		TlsConfig:   selfTLSConfig(p),
+	}
+	if HasBasicAuth(p) {
+		sc.BasicAuth = &BasicAuth{
+			Username:     ReloaderUsername,
+			PasswordFile: webConfigDir + "/" + ReloaderPasswordKey,
+		}
+	}
+
+	if UsesDeployment(p) {
+		sc.StaticConfigs = []StaticConfig{{Targets: []string{net.JoinHostPort("localhost", strconv.Itoa(prometheusPort))}}}
+		return sc
+	}
+	sc.DNSSDConfigs = []DNSSDConfig{
+		{
+			Names: []string{fmt.Sprintf("%s%s.%s.svc", p.Name, GoverningServiceSuffix, p.Namespace)},
+			Type:  "A",
+			Port:  prometheusPort,
+		},
+	}
+	return sc
+}
+
+// selfTLSConfig returns the TLS config of the self-scrape job. The replicas are verified against
+// the web TLS CA when given, under the server name of their certificate as the DNS SD targets
+// are pod IPs.
+func selfTLSConfig(p *monitoringv1alpha1.Prometheus) TLSConfig {
+	if !HasWebTLS(p) {
+		return TLSConfig{}
+	}
+	if p.Spec.Web.TLS.CA == nil {
+		return TLSConfig{InsecureSkipVerify: true}
+	}
+	return TLSConfig{CAFile: webTLSDir + "/" + webTLSCAFile, ServerName: ServerName(p)}
+}
@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestSelfScrapeConfig(t *testing.T) {
+	p := newTestPrometheus("v2.47.0")
+	p.Spec.SelfMonitor = true
+	p.Spec.Web = &monitoringv1alpha1.WebSpec{
+		BasicAuthUsers: &corev1.LocalObjectReference{Name: "users"},
+		RoutePrefix:    "/prometheus",
+	}
+
+	got, err := yaml.Marshal(selfScrapeConfig(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `job_name: prometheus
+scheme: http
+metrics_path: /prometheus/metrics
+basic_auth:
+  username: prometheus-operator
+  password_file: /etc/prometheus/web/reloader-password
+dns_sd_configs:
+- names:
+  - test-headless.monitoring.svc
+  type: A
+  port: 9090
+`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("unexpected self-scrape job (-want +got):\n%s", diff)
+	}
+	if JobCount(p, SpecTargetGroups(p)) != 3 {
+		t.Errorf("expected the static, gs and prometheus jobs, got %d jobs", JobCount(p, SpecTargetGroups(p)))
+	}
+}
+
+func TestSelfTLSConfig(t *testing.T) {
+	p := newTestPrometheus("v2.47.0")
+	p.Spec.SelfMonitor = true
+	p.Spec.Web = &monitoringv1alpha1.WebSpec{TLS: newTestWebTLS()}
+
+	want := TLSConfig{CAFile: "/etc/prometheus/web-tls/ca.crt", ServerName: "test.monitoring.svc"}
+	if diff := cmp.Diff(want, selfScrapeConfig(p).TlsConfig); diff != "" {
+		t.Errorf("unexpected TLS config (-want +got):\n%s", diff)
+	}
+
+	p.Spec.Web.TLS.CA = nil
+	if got := selfScrapeConfig(p).TlsConfig; !got.InsecureSkipVerify {
+		t.Errorf("expected verification to be skipped without CA, got %+v", got)
+	}
+}
@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// GoverningServiceSuffix suffix of the headless Service governing the StatefulSets
+const GoverningServiceSuffix = "-headless"
+
+func httpServicePort() corev1.ServicePort {
+	return corev1.ServicePort{
+		Name:       "http",
+		Port:       prometheusPort,
+		Protocol:   corev1.ProtocolTCP,
+		TargetPort: intstr.FromInt(prometheusPort),
+	}
+}
+
+func serviceType(p *monitoringv1alpha1.Prometheus) corev1.ServiceType {
+	if p.Spec.Service.Type == "" {
+		return corev1.ServiceTypeClusterIP
+	}
+	return p.Spec.Service.Type
+}
+
+func sessionAffinity(p *monitoringv1alpha1.Prometheus) corev1.ServiceAffinity {
+	if p.Spec.Service.SessionAffinity == "" {
+		return corev1.ServiceAffinityClientIP
+	}
+	return p.Spec.Service.SessionAffinity
+}
+
+// servicePorts returns the "http" port followed by the additional ports, with the
+// protocol and target port defaulted like the API server does.
+func servicePorts(p *monitoringv1alpha1.Prometheus) []corev1.ServicePort {
+	ports := []corev1.ServicePort{httpServicePort()}
+	for _, port := range p.Spec.Service.Ports {
+		port = *port.DeepCopy()
+		if port.Protocol == "" {
+			port.Protocol = corev1.ProtocolTCP
+		}
+		if port.TargetPort.IntValue() == 0 && port.TargetPort.Type == intstr.Int {
+			port.TargetPort = intstr.FromInt(int(port.Port))
+		}
+		ports = append(ports, port)
+	}
+	return ports
+}
+
+// DesiredService returns the client-facing Service of the Prometheus.
+func DesiredService(p *monitoringv1alpha1.Prometheus) corev1.Service {
+	svcLabels := make(map[string]string, len(p.Spec.Service.Labels)+1)
+	for k, v := range p.Spec.Service.Labels {
+		svcLabels[k] = v
+	}
+	for k, v := range labels(p.Name) {
+		svcLabels[k] = v
+	}
+
+	return corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        p.Name,
+			Namespace:   p.Namespace,
+			Labels:      svcLabels,
+			Annotations: p.Spec.Service.Annotations,
+		},
+		Spec: corev1.ServiceSpec{
+			Type:                     serviceType(p),
+			Ports:                    servicePorts(p),
+			SessionAffinity:          sessionAffinity(p),
+			LoadBalancerSourceRanges: p.Spec.Service.LoadBalancerSourceRanges,
+			Selector:                 labels(p.Name),
+		},
+	}
+}
+
+// DesiredGoverningService returns the headless Service giving the pods of the StatefulSets
+// a stable DNS name, and false when Prometheus runs as a Deployment.
+func DesiredGoverningService(p *monitoringv1alpha1.Prometheus) (corev1.Service, bool) {
+	if UsesDeployment(p) {
+		return corev1.Service{}, false
+	}
+	return corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: p.Name + GoverningServiceSuffix, Namespace: p.Namespace, Labels: labels(p.Name)},
+		Spec: corev1.ServiceSpec{
+			ClusterIP: corev1.ClusterIPNone,
+			Ports:     []corev1.ServicePort{httpServicePort()},
+			Selector:  labels(p.Name),
+		},
+	}, true
+}
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"net/url"
+	"path"
+
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	scrapeSecretsVolume = "scrape-secrets"
+	// scrapeSecretsDir holds the Secret keys the jobs authenticate with, as <secret>/<key>
+	scrapeSecretsDir = "/etc/prometheus/secrets"
+)
+
+func dnsSDConfigs(sc monitoringv1alpha1.ScrapeConfig) []DNSSDConfig {
+	var r []DNSSDConfig
+	for _, d := range sc.DNSSDConfigs {
+		r = append(r, DNSSDConfig{
+			Names:           d.Names,
+			RefreshInterval: d.RefreshInterval,
+			Type:            d.Type,
+			Port:            int(d.Port),
+		})
+	}
+	return r
+}
+
+func httpSDConfigs(sc monitoringv1alpha1.ScrapeConfig) []HTTPSDConfig {
+	var r []HTTPSDConfig
+	for _, h := range sc.HTTPSDConfigs {
+		c := HTTPSDConfig{
+			URL:             h.URL,
+			RefreshInterval: h.RefreshInterval,
+			TlsConfig:       tlsConfig(h.TlsConfig),
+		}
+		if h.BasicAuth != nil {
+			c.BasicAuth = &BasicAuth{Username: h.BasicAuth.Username, PasswordFile: secretFile(h.BasicAuth.Password)}
+		}
+		if h.BearerToken != nil {
+			c.BearerTokenFile = secretFile(*h.BearerToken)
+		}
+		r = append(r, c)
+	}
+	return r
+}
+
+func consulSDConfigs(sc monitoringv1alpha1.ScrapeConfig) []ConsulSDConfig {
+	var r []ConsulSDConfig
+	for _, c := range sc.ConsulSDConfigs {
+		r = append(r, ConsulSDConfig{
+			Server:          c.Server,
+			Scheme:          c.Scheme,
+			Datacenter:      c.Datacenter,
+			Services:        c.Services,
+			Tags:            c.Tags,
+			NodeMeta:        c.NodeMeta,
+			AllowStale:      c.AllowStale,
+			RefreshInterval: c.RefreshInterval,
+			TlsConfig:       tlsConfig(c.TlsConfig),
+		})
+	}
+	return r
+}
+
+// secretFile returns the path a Secret key is mounted at in the Prometheus container.
+func secretFile(s corev1.SecretKeySelector) string {
+	return path.Join(scrapeSecretsDir, s.Name, s.Key)
+}
+
+// scrapeSecrets returns the Secret keys the service discovery of the jobs authenticates with,
+// each key once.
+func scrapeSecrets(p *monitoringv1alpha1.Prometheus) []corev1.SecretKeySelector {
+	var r []corev1.SecretKeySelector
+	seen := map[string]bool{}
+	add := func(s corev1.SecretKeySelector) {
+		if !seen[secretFile(s)] {
+			seen[secretFile(s)] = true
+			r = append(r, s)
+		}
+	}
+	for _, sc := range p.Spec.AdditionalScrapeConfig {
+		for _, h := range sc.HTTPSDConfigs {
+			if h.BasicAuth != nil {
+				add(h.BasicAuth.Password)
+			}
+			if h.BearerToken != nil {
+				add(*h.BearerToken)
+			}
+		}
+	}
+	return r
+}
+
+func scrapeSecretVolumes(p *monitoringv1alpha1.Prometheus) []corev1.Volume {
+	secrets := scrapeSecrets(p)
+	if len(secrets) == 0 {
+		return nil
+	}
+	sources := make([]corev1.VolumeProjection, 0, len(secrets))
+	for _, s := range secrets {
+		sources = append(sources, secretProjection(s, path.Join(s.Name, s.Key)))
+	}
+	return []corev1.Volume{
+		{
+			Name: scrapeSecretsVolume,
+			VolumeSource: corev1.VolumeSource{
+				Projected: &corev1.ProjectedVolumeSource{Sources: sources},
+			},
+		},
+	}
+}
+
+func scrapeSecretVolumeMounts(p *monitoringv1alpha1.Prometheus) []corev1.VolumeMount {
+	if len(scrapeSecrets(p)) == 0 {
+		return nil
+	}
+	return []corev1.VolumeMount{{Name: scrapeSecretsVolume, MountPath: scrapeSecretsDir, ReadOnly: true}}
+}
+
+// serviceDiscoveryHosts returns the hosts the service discovery of the jobs queries.
+func serviceDiscoveryHosts(sc monitoringv1alpha1.ScrapeConfig) []string {
+	var hosts []string
+	for _, h := range sc.HTTPSDConfigs {
+		if u, err := url.Parse(h.URL); err == nil {
+			hosts = append(hosts, u.Hostname())
+		}
+	}
+	for _, c := range sc.ConsulSDConfigs {
+		if c.Server != "" {
+			hosts = append(hosts, targetHost(c.Server))
+		}
+	}
+	return hosts
+}
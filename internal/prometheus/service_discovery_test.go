@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"path/filepath"
+	"testing"
+
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestServiceDiscoveryConfigs(t *testing.T) {
+	allowStale := false
+	configs := map[string]monitoringv1alpha1.ScrapeConfig{
+		"dns": {
+			JobName: "dns",
+			DNSSDConfigs: []monitoringv1alpha1.DNSSDConfig{
+				{Names: []string{"_metrics._tcp.example.org"}, Type: "SRV", RefreshInterval: "1m"},
+				{Names: []string{"nodes.example.org"}, Type: "A", Port: 9100},
+			},
+		},
+		"http": {
+			JobName: "http",
+			HTTPSDConfigs: []monitoringv1alpha1.HTTPSDConfig{
+				{
+					URL:             "https://sd.example.org/targets",
+					RefreshInterval: "5m",
+					BasicAuth: &monitoringv1alpha1.BasicAuth{
+						Username: "prometheus",
+						Password: corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "sd-auth"}, Key: "password"},
+					},
+					TlsConfig: &monitoringv1alpha1.TLSConfig{InsecureSkipVerify: true},
+				},
+				{
+					URL:         "http://sd.monitoring:8080/targets",
+					BearerToken: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "sd-auth"}, Key: "token"},
+				},
+			},
+		},
+		"consul": {
+			JobName: "consul",
+			ConsulSDConfigs: []monitoringv1alpha1.ConsulSDConfig{
+				{
+					Server:     "consul.example.org:8500",
+					Datacenter: "dc1",
+					Services:   []string{"api", "web"},
+					Tags:       []string{"prometheus"},
+					NodeMeta:   map[string]string{"rack": "a"},
+					AllowStale: &allowStale,
+				},
+			},
+		},
+	}
+
+	for name, sc := range configs {
+		t.Run(name, func(t *testing.T) {
+			out, err := yaml.Marshal(getPrometheusScrapeConfig([]monitoringv1alpha1.ScrapeConfig{sc}, nil)[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertGolden(t, filepath.Join("testdata", "sd", name+".golden"), string(out))
+		})
+	}
+}
+
+func TestScrapeSecretVolumes(t *testing.T) {
+	p := newTestPrometheus("v2.47.0")
+	password := corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "sd-auth"}, Key: "password"}
+	p.Spec.AdditionalScrapeConfig[0].HTTPSDConfigs = []monitoringv1alpha1.HTTPSDConfig{
+		{URL: "https://a.example.org", BasicAuth: &monitoringv1alpha1.BasicAuth{Username: "a", Password: password}},
+		{URL: "https://b.example.org", BasicAuth: &monitoringv1alpha1.BasicAuth{Username: "b", Password: password}},
+	}
+
+	volumes := scrapeSecretVolumes(p)
+	if len(volumes) != 1 || len(volumes[0].Projected.Sources) != 1 {
+		t.Fatalf("expected a single projected Secret key, got %+v", volumes)
+	}
+	if got := volumes[0].Projected.Sources[0].Secret.Items[0].Path; got != "sd-auth/password" {
+		t.Errorf("unexpected projected path %v", got)
+	}
+}
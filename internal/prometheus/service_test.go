@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestDesiredService(t *testing.T) {
+	p := newTestPrometheus("v2.47.0")
+	p.Spec.Service = monitoringv1alpha1.ServiceSpec{
+		Labels: map[string]string{"team": "a", "app.kubernetes.io/name": "other"},
+		Ports:  []corev1.ServicePort{{Name: "metrics", Port: 8080}},
+	}
+
+	svc := DesiredService(p)
+	wantLabels := map[string]string{"team": "a", "app.kubernetes.io/component": "prometheus", "app.kubernetes.io/name": "test"}
+	if diff := cmp.Diff(wantLabels, svc.Labels); diff != "" {
+		t.Errorf("unexpected labels (-want +got):\n%s", diff)
+	}
+	wantPorts := []corev1.ServicePort{
+		httpServicePort(),
+		{Name: "metrics", Port: 8080, Protocol: corev1.ProtocolTCP, TargetPort: intstr.FromInt(8080)},
+	}
+	if diff := cmp.Diff(wantPorts, svc.Spec.Ports); diff != "" {
+		t.Errorf("unexpected ports (-want +got):\n%s", diff)
+	}
+	if svc.Spec.Type != corev1.ServiceTypeClusterIP || svc.Spec.SessionAffinity != corev1.ServiceAffinityClientIP {
+		t.Errorf("unexpected defaults: type %v, session affinity %v", svc.Spec.Type, svc.Spec.SessionAffinity)
+	}
+}
+
+func TestDesiredGoverningService(t *testing.T) {
+	p := newTestPrometheus("v2.47.0")
+	svc, needed := DesiredGoverningService(p)
+	if !needed {
+		t.Fatal("no governing Service for the StatefulSets")
+	}
+	if svc.Name != "test"+GoverningServiceSuffix || svc.Spec.ClusterIP != corev1.ClusterIPNone {
+		t.Errorf("unexpected governing Service %v with cluster IP %q", svc.Name, svc.Spec.ClusterIP)
+	}
+	if diff := cmp.Diff(labels(p.Name), svc.Spec.Selector); diff != "" {
+		t.Errorf("unexpected selector (-want +got):\n%s", diff)
+	}
+
+	p.Spec.Mode = monitoringv1alpha1.ModeAgent
+	p.Spec.EphemeralStorage = true
+	if _, needed := DesiredGoverningService(p); needed {
+		t.Error("governing Service for Deployments")
+	}
+}
@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+)
+
+const (
+	ShardLabel         = "monitoring.giantswarm.io/shard"
+	shardExternalLabel = "shard"
+)
+
+// Shards returns the number of shards of the Prometheus.
+func Shards(p *monitoringv1alpha1.Prometheus) int32 {
+	if p.Spec.Shards == nil || *p.Spec.Shards < 1 {
+		return 1
+	}
+	return *p.Spec.Shards
+}
+
+// ShardName returns the name of the StatefulSet of a shard. The first shard keeps
+// the name of the Prometheus, so enabling sharding does not recreate it.
+func ShardName(p *monitoringv1alpha1.Prometheus, shard int32) string {
+	if shard == 0 {
+		return p.Name
+	}
+	return fmt.Sprintf("%s-shard-%d", p.Name, shard)
+}
+
+// ShardOf returns the shard of a resource from its labels.
+func ShardOf(l map[string]string) (int32, bool) {
+	v, ok := l[ShardLabel]
+	if !ok {
+		return 0, false
+	}
+	shard, err := strconv.ParseInt(v, 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return int32(shard), true
+}
+
+// ShardOfVolumeClaim returns the shard owning a PersistentVolumeClaim created
+// from the VolumeClaimTemplate of one of the shard StatefulSets.
+func ShardOfVolumeClaim(p *monitoringv1alpha1.Prometheus, claimName string) (int32, bool) {
+	prefix := volumeClaimTemplate(p).Name + "-" + p.Name + "-shard-"
+	if !strings.HasPrefix(claimName, prefix) {
+		return 0, false
+	}
+	// <shard>-<ordinal>
+	parts := strings.Split(strings.TrimPrefix(claimName, prefix), "-")
+	if len(parts) != 2 {
+		return 0, false
+	}
+	shard, err := strconv.ParseInt(parts[0], 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return int32(shard), true
+}
+
+func shardLabels(p *monitoringv1alpha1.Prometheus, shard int32) map[string]string {
+	l := labels(p.Name)
+	l[ShardLabel] = strconv.Itoa(int(shard))
+	return l
+}
+
+// shardRelabelConfigs keeps the targets whose source label hashes to the shard.
+func shardRelabelConfigs(source string, shard int32, shards int32) []RelabelConfig {
+	return []RelabelConfig{
+		{
+			SourceLabels: []string{source},
+			Modulus:      uint64(shards),
+			TargetLabel:  "__tmp_hash",
+			Action:       "hashmod",
+		},
+		{
+			SourceLabels: []string{"__tmp_hash"},
+			Regex:        strconv.Itoa(int(shard)),
+			Action:       "keep",
+		},
+	}
+}
+
+// shardSourceLabel returns the label identifying the targets of a job across shards. Probe jobs
+// rewrite the address to the prober, so they hash the probed target instead.
+func shardSourceLabel(job string, tg TargetGroups) string {
+	for i := range tg.Probes {
+		if ProbeJobName(&tg.Probes[i]) == job {
+			return "__param_target"
+		}
+	}
+	return "__address__"
+}
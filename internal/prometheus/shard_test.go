@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+	"gopkg.in/yaml.v2"
+)
+
+func newTestShardedPrometheus(shards int32) *monitoringv1alpha1.Prometheus {
+	p := newTestPrometheus("v2.47.0")
+	p.Spec.Shards = &shards
+	p.Spec.ExternalLabels = map[string]string{"cluster": "test"}
+	return p
+}
+
+func TestShardName(t *testing.T) {
+	p := newTestShardedPrometheus(3)
+	for shard, want := range []string{"test", "test-shard-1", "test-shard-2"} {
+		if got := ShardName(p, int32(shard)); got != want {
+			t.Errorf("ShardName(%d) = %q, want %q", shard, got, want)
+		}
+	}
+}
+
+func TestShardOfVolumeClaim(t *testing.T) {
+	p := newTestShardedPrometheus(3)
+	tests := []struct {
+		claim string
+		shard int32
+		ok    bool
+	}{
+		{claim: "test-test-shard-2-0", shard: 2, ok: true},
+		{claim: "test-test-shard-10-1", shard: 10, ok: true},
+		// The first shard is never removed
+		{claim: "test-test-0"},
+		{claim: "test-test-shard-x-0"},
+		{claim: "test-test-shard-1"},
+		{claim: "other-test-shard-1-0"},
+	}
+	for _, tt := range tests {
+		shard, ok := ShardOfVolumeClaim(p, tt.claim)
+		if shard != tt.shard || ok != tt.ok {
+			t.Errorf("ShardOfVolumeClaim(%q) = %d, %v, want %d, %v", tt.claim, shard, ok, tt.shard, tt.ok)
+		}
+	}
+}
+
+func TestShardSelectors(t *testing.T) {
+	p := newTestShardedPrometheus(2)
+	first, err := DesiredStatefulSet(p, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := DesiredStatefulSet(p, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"app.kubernetes.io/component": "prometheus", "app.kubernetes.io/name": "test", ShardLabel: "0"}
+	if diff := cmp.Diff(want, first.Spec.Selector.MatchLabels); diff != "" {
+		t.Errorf("unexpected selector of the first shard (-want +got):\n%s", diff)
+	}
+	if cmp.Equal(first.Spec.Selector, second.Spec.Selector) {
+		t.Error("shards share their selector")
+	}
+}
+
+func TestShardRelabelConfigs(t *testing.T) {
+	p := newTestShardedPrometheus(2)
+	p.Spec.SelfMonitor = true
+
+	cm, err := DesiredPrometheusConfigMap(p, 1, SpecTargetGroups(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg PrometheusConfigFile
+	if err := yaml.Unmarshal([]byte(cm.Data[PrometheusConfigKey]), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(map[string]string{"cluster": "test", "shard": "1"}, cfg.Global.ExternalLabels); diff != "" {
+		t.Errorf("unexpected external labels (-want +got):\n%s", diff)
+	}
+
+	want := []RelabelConfig{
+		{SourceLabels: []string{"__address__"}, Modulus: 2, TargetLabel: "__tmp_hash", Action: "hashmod"},
+		{SourceLabels: []string{"__tmp_hash"}, Regex: "1", Action: "keep"},
+	}
+	for _, sc := range cfg.ScrapeConfigs {
+		if sc.JobName == selfMonitorJobName {
+			if len(sc.RelabelConfigs) != 0 {
+				t.Errorf("self-scrape job sharded: %v", sc.RelabelConfigs)
+			}
+			continue
+		}
+		if diff := cmp.Diff(want, sc.RelabelConfigs); diff != "" {
+			t.Errorf("unexpected relabeling of job %v (-want +got):\n%s", sc.JobName, diff)
+		}
+	}
+
+	single, err := DesiredPrometheusConfigMap(newTestPrometheus("v2.47.0"), 0, SpecTargetGroups(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(single.Data[PrometheusConfigKey], "hashmod") {
+		t.Error("unsharded Prometheus relabeled by shard")
+	}
+}
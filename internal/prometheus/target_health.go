@@ -0,0 +1,174 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	defaultTargetHealthInterval = time.Minute
+
+	targetHealthUp   = "up"
+	targetHealthDown = "down"
+)
+
+// ActiveTarget is an active target reported by the targets API of a replica
+type ActiveTarget struct {
+	DiscoveredLabels map[string]string `json:"discoveredLabels"`
+	Labels           map[string]string `json:"labels"`
+	ScrapePool       string            `json:"scrapePool"`
+	ScrapeURL        string            `json:"scrapeUrl"`
+	LastError        string            `json:"lastError"`
+	Health           string            `json:"health"`
+}
+
+type targetsResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		ActiveTargets []ActiveTarget `json:"activeTargets"`
+	} `json:"data"`
+}
+
+// TargetHealthInterval returns the interval between two polls of the target health.
+func TargetHealthInterval(p *monitoringv1alpha1.Prometheus) time.Duration {
+	if p.Spec.TargetHealth == nil || p.Spec.TargetHealth.Interval.Duration <= 0 {
+		return defaultTargetHealthInterval
+	}
+	return p.Spec.TargetHealth.Interval.Duration
+}
+
+// TargetsURL returns the URL of the active targets API of a replica.
+func TargetsURL(p *monitoringv1alpha1.Prometheus, host string) string {
+	return URL(p, host) + "/api/v1/targets?state=active"
+}
+
+// FetchActiveTargets returns the active targets of a replica, authenticating as the reloader
+// when password is set.
+func FetchActiveTargets(ctx context.Context, c *http.Client, url, password string) ([]ActiveTarget, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if password != "" {
+		req.SetBasicAuth(ReloaderUsername, password)
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %v", resp.Status)
+	}
+
+	var body targetsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("unable to decode targets: %v", err)
+	}
+	if body.Status != "success" {
+		return nil, fmt.Errorf("targets API failed: %v", body.Error)
+	}
+	return body.Data.ActiveTargets, nil
+}
+
+// TargetHealthSummary is the health of the targets of all replicas
+type TargetHealthSummary struct {
+	// Jobs health per job, sorted by job name
+	Jobs []monitoringv1alpha1.JobHealth
+	// ScrapeTargets health of the targets defined by each ScrapeTarget
+	ScrapeTargets map[types.NamespacedName]monitoringv1alpha1.TargetHealth
+}
+
+type targetState struct {
+	target ActiveTarget
+	down   bool
+	up     bool
+}
+
+// SummarizeTargetHealth summarizes the active targets reported by each replica. A target is
+// identified by its job and scrape URL, and is down as soon as one replica reports it down.
+func SummarizeTargetHealth(replicas [][]ActiveTarget) TargetHealthSummary {
+	states := map[string]*targetState{}
+	var keys []string
+	for _, targets := range replicas {
+		for _, t := range targets {
+			key := targetJob(t) + "|" + t.ScrapeURL
+			state, ok := states[key]
+			if !ok {
+				state = &targetState{target: t, up: true}
+				states[key] = state
+				keys = append(keys, key)
+			}
+			switch t.Health {
+			case targetHealthDown:
+				if !state.down {
+					state.target = t
+				}
+				state.down = true
+			case targetHealthUp:
+			default:
+				// Not scraped yet
+				state.up = false
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	jobs := map[string]*monitoringv1alpha1.TargetHealth{}
+	var jobNames []string
+	summary := TargetHealthSummary{ScrapeTargets: map[types.NamespacedName]monitoringv1alpha1.TargetHealth{}}
+	for _, key := range keys {
+		state := states[key]
+		job := targetJob(state.target)
+		if _, ok := jobs[job]; !ok {
+			jobs[job] = &monitoringv1alpha1.TargetHealth{}
+			jobNames = append(jobNames, job)
+		}
+		countTarget(jobs[job], state)
+
+		nn := types.NamespacedName{
+			Namespace: state.target.DiscoveredLabels[ScrapeTargetNamespaceLabel],
+			Name:      state.target.DiscoveredLabels[ScrapeTargetNameLabel],
+		}
+		if nn.Name != "" {
+			health := summary.ScrapeTargets[nn]
+			countTarget(&health, state)
+			summary.ScrapeTargets[nn] = health
+		}
+	}
+
+	sort.Strings(jobNames)
+	for _, job := range jobNames {
+		summary.Jobs = append(summary.Jobs, monitoringv1alpha1.JobHealth{Job: job, TargetHealth: *jobs[job]})
+	}
+	return summary
+}
+
+func countTarget(health *monitoringv1alpha1.TargetHealth, state *targetState) {
+	health.Active++
+	switch {
+	case state.down:
+		health.Down++
+		if health.LastError == "" {
+			health.LastError = fmt.Sprintf("%s: %s", state.target.ScrapeURL, state.target.LastError)
+		}
+	case state.up:
+		health.Up++
+	}
+}
+
+// targetJob returns the job of a target, older versions not reporting the scrape pool.
+func targetJob(t ActiveTarget) string {
+	if t.ScrapePool != "" {
+		return t.ScrapePool
+	}
+	return t.Labels["job"]
+}
@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const targetsBody = `{
+  "status": "success",
+  "data": {
+    "activeTargets": [
+      {
+        "discoveredLabels": {"__address__": "localhost:9090"},
+        "labels": {"instance": "localhost:9090", "job": "static"},
+        "scrapePool": "static",
+        "scrapeUrl": "http://localhost:9090/metrics",
+        "lastError": "",
+        "health": "up"
+      },
+      {
+        "discoveredLabels": {"__address__": "app:8080", "__meta_scrapetarget_namespace": "apps", "__meta_scrapetarget_name": "app"},
+        "labels": {"instance": "app:8080", "job": "gs"},
+        "scrapePool": "gs",
+        "scrapeUrl": "http://app:8080/metrics",
+        "lastError": "connection refused",
+        "health": "down"
+      }
+    ]
+  }
+}`
+
+func newTargetsServer(t *testing.T, status int, body string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/targets" || r.URL.Query().Get("state") != "active" {
+			http.NotFound(w, r)
+			return
+		}
+		if user, password, ok := r.BasicAuth(); !ok || user != ReloaderUsername || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetchActiveTargets(t *testing.T) {
+	server := newTargetsServer(t, http.StatusOK, targetsBody)
+	url := server.URL + "/api/v1/targets?state=active"
+
+	targets, err := FetchActiveTargets(context.Background(), server.Client(), url, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 2 || targets[1].ScrapePool != "gs" || targets[1].Health != "down" {
+		t.Errorf("unexpected targets %+v", targets)
+	}
+
+	if _, err := FetchActiveTargets(context.Background(), server.Client(), url, "wrong"); err == nil {
+		t.Error("expected an error for an unauthorized request")
+	}
+
+	server = newTargetsServer(t, http.StatusOK, `{"status": "error", "error": "not ready"}`)
+	if _, err := FetchActiveTargets(context.Background(), server.Client(), server.URL+"/api/v1/targets?state=active", "secret"); err == nil {
+		t.Error("expected an error for a failed response")
+	}
+}
+
+func TestSummarizeTargetHealth(t *testing.T) {
+	server := newTargetsServer(t, http.StatusOK, targetsBody)
+	first, err := FetchActiveTargets(context.Background(), server.Client(), server.URL+"/api/v1/targets?state=active", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The second replica scrapes the application successfully and did not scrape itself yet
+	second := []ActiveTarget{
+		{Labels: map[string]string{"job": "static"}, ScrapeURL: "http://localhost:9090/metrics", Health: "unknown"},
+		{
+			DiscoveredLabels: map[string]string{ScrapeTargetNamespaceLabel: "apps", ScrapeTargetNameLabel: "app"},
+			ScrapePool:       "gs",
+			ScrapeURL:        "http://app:8080/metrics",
+			Health:           "up",
+		},
+	}
+
+	summary := SummarizeTargetHealth([][]ActiveTarget{first, second})
+	wantJobs := []monitoringv1alpha1.JobHealth{
+		{Job: "gs", TargetHealth: monitoringv1alpha1.TargetHealth{Active: 1, Down: 1, LastError: "http://app:8080/metrics: connection refused"}},
+		{Job: "static", TargetHealth: monitoringv1alpha1.TargetHealth{Active: 1}},
+	}
+	if diff := cmp.Diff(wantJobs, summary.Jobs); diff != "" {
+		t.Errorf("unexpected job health (-want +got):\n%s", diff)
+	}
+	wantScrapeTargets := map[types.NamespacedName]monitoringv1alpha1.TargetHealth{
+		{Namespace: "apps", Name: "app"}: {Active: 1, Down: 1, LastError: "http://app:8080/metrics: connection refused"},
+	}
+	if diff := cmp.Diff(wantScrapeTargets, summary.ScrapeTargets); diff != "" {
+		t.Errorf("unexpected ScrapeTarget health (-want +got):\n%s", diff)
+	}
+}
+
+func TestTargetHealthInterval(t *testing.T) {
+	p := newTestPrometheus("v2.47.0")
+	if got := TargetHealthInterval(p); got != defaultTargetHealthInterval {
+		t.Errorf("expected the default interval, got %v", got)
+	}
+	if got := TargetsURL(p, "10.0.0.1"); got != "http://10.0.0.1:9090/api/v1/targets?state=active" {
+		t.Errorf("unexpected targets URL %v", got)
+	}
+}
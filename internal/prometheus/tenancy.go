@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8slabels "k8s.io/apimachinery/pkg/labels"
+)
+
+// SelectedNamespaces returns the names of the namespaces matching selector, nil meaning any
+// namespace when the selector is unset.
+func SelectedNamespaces(selector *metav1.LabelSelector, namespaces []corev1.Namespace) (map[string]bool, error) {
+	if selector == nil {
+		return nil, nil
+	}
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	selected := map[string]bool{}
+	for _, ns := range namespaces {
+		if s.Matches(k8slabels.Set(ns.Labels)) {
+			selected[ns.Name] = true
+		}
+	}
+	return selected, nil
+}
+
+// UsesNamespaceSelectors returns whether the Prometheus restricts the namespaces of its ScrapeTargets or Probes.
+func UsesNamespaceSelectors(p *monitoringv1alpha1.Prometheus) bool {
+	return p.Spec.ScrapeTargetNamespaceSelector != nil || p.Spec.ProbeNamespaceSelector != nil
+}
+
+// scrapeTargetNamespaceRelabelConfigs returns the relabeling enforcing the namespace label on
+// the targets of ScrapeTargets, identified by their ScrapeTargetNamespaceLabel. The targets of
+// the spec are left as is.
+func scrapeTargetNamespaceRelabelConfigs(p *monitoringv1alpha1.Prometheus) []RelabelConfig {
+	if p.Spec.EnforcedNamespaceLabel == "" {
+		return nil
+	}
+	return []RelabelConfig{
+		{
+			SourceLabels: []string{ScrapeTargetNamespaceLabel},
+			Regex:        "(.+)",
+			TargetLabel:  p.Spec.EnforcedNamespaceLabel,
+			Replacement:  "$1",
+		},
+	}
+}
+
+// probeNamespaceRelabelConfigs returns the relabeling enforcing the namespace label on the targets of a Probe.
+func probeNamespaceRelabelConfigs(p *monitoringv1alpha1.Prometheus, probe *monitoringv1alpha1.Probe) []RelabelConfig {
+	if p.Spec.EnforcedNamespaceLabel == "" {
+		return nil
+	}
+	return []RelabelConfig{{TargetLabel: p.Spec.EnforcedNamespaceLabel, Replacement: probe.Namespace}}
+}
+
+// probeIngressNamespaces returns the namespaces the Ingresses of a Probe are discovered in.
+func probeIngressNamespaces(p *monitoringv1alpha1.Prometheus, probe *monitoringv1alpha1.Probe) []string {
+	namespaces := probe.Spec.Targets.Ingress.Namespaces
+	if len(namespaces) == 0 || p.Spec.IgnoreNamespaceSelectors {
+		return []string{probe.Namespace}
+	}
+	return namespaces
+}
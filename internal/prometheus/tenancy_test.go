@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSelectedNamespaces(t *testing.T) {
+	namespaces := []corev1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "team-a", Labels: map[string]string{"tenant": "a"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "team-b", Labels: map[string]string{"tenant": "b"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+	}
+
+	selected, err := SelectedNamespaces(nil, namespaces)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if selected != nil {
+		t.Errorf("unset selector selected %v, want any namespace", selected)
+	}
+
+	selector := &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+		{Key: "tenant", Operator: metav1.LabelSelectorOpExists},
+	}}
+	selected, err = SelectedNamespaces(selector, namespaces)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(map[string]bool{"team-a": true, "team-b": true}, selected); diff != "" {
+		t.Errorf("unexpected namespaces (-want +got):\n%s", diff)
+	}
+
+	selector = &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+		{Key: "tenant", Operator: "Unknown"},
+	}}
+	if _, err := SelectedNamespaces(selector, namespaces); err == nil {
+		t.Error("expected an invalid selector to be rejected")
+	}
+}
+
+func TestEnforcedNamespaceLabel(t *testing.T) {
+	p := newTestPrometheus("v2.47.0")
+	p.Spec.EnforcedNamespaceLabel = "namespace"
+	probe := newTestProbe("static", monitoringv1alpha1.ProbeTargets{
+		StaticConfig: &monitoringv1alpha1.ProbeStaticConfig{Static: []string{"https://example.org"}},
+	})
+	tg := TargetGroups{
+		Groups: []monitoringv1alpha1.PrometheusTarget{{JobName: "app", Targets: []string{"app.team-a.svc:8080"}}},
+		Probes: []monitoringv1alpha1.Probe{probe},
+	}
+
+	enforced := RelabelConfig{
+		SourceLabels: []string{ScrapeTargetNamespaceLabel},
+		Regex:        "(.+)",
+		TargetLabel:  "namespace",
+		Replacement:  "$1",
+	}
+	relabeled := map[string][]RelabelConfig{}
+	for _, sc := range scrapeConfigs(p, tg) {
+		relabeled[sc.JobName] = sc.RelabelConfigs
+	}
+	if len(relabeled["static"]) != 0 {
+		t.Errorf("spec job relabeled with %v", relabeled["static"])
+	}
+	for _, job := range []string{legacyJobName, "app"} {
+		rcs := relabeled[job]
+		if len(rcs) == 0 || !cmp.Equal(rcs[len(rcs)-1], enforced) {
+			t.Errorf("job %v does not end with the enforced namespace label: %v", job, rcs)
+		}
+	}
+	rcs := relabeled[ProbeJobName(&probe)]
+	want := RelabelConfig{TargetLabel: "namespace", Replacement: "web"}
+	if len(rcs) == 0 || !cmp.Equal(rcs[len(rcs)-1], want) {
+		t.Errorf("probe job does not end with the enforced namespace label: %v", rcs)
+	}
+}
+
+func TestIgnoreNamespaceSelectors(t *testing.T) {
+	p := newTestPrometheus("v2.47.0")
+	probe := newTestProbe("ingress", monitoringv1alpha1.ProbeTargets{
+		Ingress: &monitoringv1alpha1.ProbeIngress{Namespaces: []string{"web", "team-b"}},
+	})
+
+	if diff := cmp.Diff([]string{"web", "team-b"}, probeIngressNamespaces(p, &probe)); diff != "" {
+		t.Errorf("unexpected namespaces (-want +got):\n%s", diff)
+	}
+	p.Spec.IgnoreNamespaceSelectors = true
+	if diff := cmp.Diff([]string{"web"}, probeIngressNamespaces(p, &probe)); diff != "" {
+		t.Errorf("unexpected namespaces with ignored selectors (-want +got):\n%s", diff)
+	}
+}
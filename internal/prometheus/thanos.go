@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"fmt"
+
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	ThanosServiceSuffix = "-thanos"
+	ThanosContainerName = "thanos-sidecar"
+	thanosRepository    = "quay.io/thanos/thanos"
+	thanosVersion       = "v0.25.2"
+	thanosGRPCPort      = 10901
+	thanosHTTPPort      = 10902
+	thanosBlockDuration = "2h"
+)
+
+func thanosPorts(t *monitoringv1alpha1.ThanosSpec) (int32, int32) {
+	grpcPort, httpPort := t.GRPCPort, t.HTTPPort
+	if grpcPort == 0 {
+		grpcPort = thanosGRPCPort
+	}
+	if httpPort == 0 {
+		httpPort = thanosHTTPPort
+	}
+	return grpcPort, httpPort
+}
+
+func thanosContainer(p *monitoringv1alpha1.Prometheus) corev1.Container {
+	t := p.Spec.Thanos
+	repository, version := t.Repository, t.Version
+	if repository == "" {
+		repository = thanosRepository
+	}
+	if version == "" {
+		version = thanosVersion
+	}
+	grpcPort, httpPort := thanosPorts(t)
+	resources := corev1.ResourceRequirements{}
+	if t.Resources != nil {
+		resources = *t.Resources.DeepCopy()
+	}
+
+	c := corev1.Container{
+		Name:            ThanosContainerName,
+		Image:           imageReference(repository, version, t.Digest),
+		ImagePullPolicy: pullPolicy(t.ImagePullPolicy),
+		SecurityContext: containerSecurityContext(p),
+		Args: []string{"sidecar",
+			"--tsdb.path=/data",
+			"--prometheus.url=" + URL(p, "127.0.0.1"),
+			fmt.Sprintf("--grpc-address=0.0.0.0:%d", grpcPort),
+			fmt.Sprintf("--http-address=0.0.0.0:%d", httpPort),
+		},
+		Ports: []corev1.ContainerPort{
+			{Name: "grpc", ContainerPort: grpcPort},
+			{Name: "thanos-http", ContainerPort: httpPort},
+		},
+		Resources: resources,
+		ReadinessProbe: &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{
+				HTTPGet: &corev1.HTTPGetAction{
+					Path: "/-/ready",
+					Port: intstr.FromInt(int(httpPort)),
+				},
+			},
+			InitialDelaySeconds: 10,
+			TimeoutSeconds:      10,
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      p.Name,
+				MountPath: "/data",
+			},
+		},
+	}
+
+	if t.ObjectStorageConfig != nil {
+		c.Env = []corev1.EnvVar{
+			{
+				Name: "OBJSTORE_CONFIG",
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: t.ObjectStorageConfig.DeepCopy(),
+				},
+			},
+		}
+		c.Args = append(c.Args, "--objstore.config=$(OBJSTORE_CONFIG)")
+	}
+	return c
+}
+
+// DesiredThanosService returns the headless Service exposing the StoreAPI of every replica
+// to a Thanos Querier, and false when Thanos is not enabled.
+func DesiredThanosService(p *monitoringv1alpha1.Prometheus) (corev1.Service, bool) {
+	if p.Spec.Thanos == nil {
+		return corev1.Service{}, false
+	}
+	grpcPort, _ := thanosPorts(p.Spec.Thanos)
+
+	return corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: p.Name + ThanosServiceSuffix, Namespace: p.Namespace, Labels: labels(p.Name)},
+		Spec: corev1.ServiceSpec{
+			ClusterIP: corev1.ClusterIPNone,
+			Ports: []corev1.ServicePort{
+				{
+					Name:       "grpc",
+					Port:       grpcPort,
+					Protocol:   "TCP",
+					TargetPort: intstr.FromString("grpc"),
+				},
+			},
+			Selector: labels(p.Name),
+		},
+	}, true
+}
@@ -0,0 +1,26 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestThanosContainerImage(t *testing.T) {
+	p := newTestPrometheus("v2.47.0")
+	p.Spec.Thanos = &monitoringv1alpha1.ThanosSpec{}
+
+	c := thanosContainer(p)
+	if c.Image != thanosRepository+":"+thanosVersion || c.ImagePullPolicy != corev1.PullIfNotPresent {
+		t.Errorf("unexpected default image %v pulled %v", c.Image, c.ImagePullPolicy)
+	}
+
+	digest := "sha256:" + strings.Repeat("a", 64)
+	p.Spec.Thanos = &monitoringv1alpha1.ThanosSpec{Version: "v0.30.0", Digest: digest, ImagePullPolicy: corev1.PullAlways}
+	c = thanosContainer(p)
+	if c.Image != thanosRepository+"@"+digest || c.ImagePullPolicy != corev1.PullAlways {
+		t.Errorf("unexpected pinned image %v pulled %v", c.Image, c.ImagePullPolicy)
+	}
+}
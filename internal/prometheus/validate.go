@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"fmt"
+	"net/url"
+
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Validate checks the Prometheus spec for combinations the operator cannot render.
+func Validate(p *monitoringv1alpha1.Prometheus) error {
+	if err := validateVersion(p); err != nil {
+		return err
+	}
+	if p.Spec.Thanos != nil && len(p.Spec.ExternalLabels) == 0 {
+		return fmt.Errorf("thanos requires externalLabels to identify the blocks of this Prometheus")
+	}
+	if _, ok := p.Spec.ExternalLabels[shardExternalLabel]; ok && Shards(p) > 1 {
+		return fmt.Errorf("external label %q is set by the operator on sharded Prometheuses", shardExternalLabel)
+	}
+	if pdb := p.Spec.PodDisruptionBudget; pdb != nil && pdb.MinAvailable != nil && pdb.MaxUnavailable != nil {
+		return fmt.Errorf("podDisruptionBudget minAvailable and maxUnavailable are mutually exclusive")
+	}
+	for _, port := range p.Spec.Service.Ports {
+		if port.Name == "http" || port.Port == prometheusPort {
+			return fmt.Errorf("service port %q conflicts with the http port of Prometheus", port.Name)
+		}
+	}
+	jobs := map[string]bool{legacyJobName: true}
+	if p.Spec.SelfMonitor {
+		jobs[selfMonitorJobName] = true
+	}
+	for _, job := range presetJobNames(p) {
+		jobs[job] = true
+	}
+	for _, sc := range p.Spec.AdditionalScrapeConfig {
+		if jobs[sc.JobName] {
+			return fmt.Errorf("job name %q is used more than once", sc.JobName)
+		}
+		jobs[sc.JobName] = true
+	}
+	for _, sc := range p.Spec.AdditionalScrapeConfig {
+		for _, k := range sc.KubernetesSDConfigs {
+			if k.AttachMetadata != nil && !attachesMetadata(k.Role) {
+				return fmt.Errorf("job %q: attachMetadata is not supported by the %s role", sc.JobName, k.Role)
+			}
+		}
+		if sc.Federate != nil && (len(sc.StaticConfigs) > 0 || len(sc.KubernetesSDConfigs) > 0 || len(sc.DNSSDConfigs) > 0 ||
+			len(sc.HTTPSDConfigs) > 0 || len(sc.ConsulSDConfigs) > 0) {
+			return fmt.Errorf("job %q: federate excludes any other target", sc.JobName)
+		}
+		if sc.Federate != nil && FederatedPrometheus(p, sc) == (types.NamespacedName{Namespace: p.Namespace, Name: p.Name}) {
+			return fmt.Errorf("job %q: a Prometheus cannot federate itself", sc.JobName)
+		}
+		for _, d := range sc.DNSSDConfigs {
+			if (d.Type == "A" || d.Type == "AAAA") && d.Port == 0 {
+				return fmt.Errorf("job %q: dnsSDConfigs of type %s require a port", sc.JobName, d.Type)
+			}
+		}
+		for _, h := range sc.HTTPSDConfigs {
+			if parsed, err := url.Parse(h.URL); err != nil || !parsed.IsAbs() {
+				return fmt.Errorf("job %q: httpSDConfigs URL %q is not an absolute URL", sc.JobName, h.URL)
+			}
+		}
+	}
+	if err := validateKubernetesSDEgress(p, kubernetesSDScrapeConfigs(p)); err != nil {
+		return err
+	}
+	// Each ConfigMap is mounted at a path derived from its name
+	configMaps := map[string]bool{}
+	for _, cm := range p.Spec.FileSDConfigMaps {
+		if configMaps[cm.Name] {
+			return fmt.Errorf("fileSDConfigMaps ConfigMap %q is listed more than once", cm.Name)
+		}
+		configMaps[cm.Name] = true
+		if jobs[FileSDJobName(cm)] {
+			return fmt.Errorf("fileSDConfigMaps job name %q is already used by another job", FileSDJobName(cm))
+		}
+		jobs[FileSDJobName(cm)] = true
+	}
+	options := map[string]monitoringv1alpha1.PrometheusTarget{}
+	for _, group := range p.Spec.Targets {
+		if group.JobName == "" {
+			continue
+		}
+		if jobs[group.JobName] {
+			return fmt.Errorf("targets job name %q is already used by another job", group.JobName)
+		}
+		if scrapeOptionsConflict(options[group.JobName], group) {
+			return fmt.Errorf("targets of job %q set conflicting scrape options", group.JobName)
+		}
+		options[group.JobName] = mergeScrapeOptions([]monitoringv1alpha1.PrometheusTarget{options[group.JobName], group})
+	}
+	if p.Spec.ScrapeTargetSelector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(p.Spec.ScrapeTargetSelector); err != nil {
+			return fmt.Errorf("invalid scrapeTargetSelector: %v", err)
+		}
+	}
+	if p.Spec.ProbeSelector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(p.Spec.ProbeSelector); err != nil {
+			return fmt.Errorf("invalid probeSelector: %v", err)
+		}
+	}
+	if p.Spec.ScrapeTargetNamespaceSelector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(p.Spec.ScrapeTargetNamespaceSelector); err != nil {
+			return fmt.Errorf("invalid scrapeTargetNamespaceSelector: %v", err)
+		}
+	}
+	if p.Spec.ProbeNamespaceSelector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(p.Spec.ProbeNamespaceSelector); err != nil {
+			return fmt.Errorf("invalid probeNamespaceSelector: %v", err)
+		}
+	}
+	if HasBasicAuth(p) && HasWebTLS(p) && p.Spec.Web.TLS.CA == nil {
+		return fmt.Errorf("web.tls.ca is required with basicAuthUsers, the reloader credentials are only sent to verified replicas")
+	}
+	if p.Spec.Thanos != nil && HasWebConfig(p) {
+		return fmt.Errorf("thanos cannot query a Prometheus served over web.tls or requiring basicAuthUsers")
+	}
+	if p.Spec.Ingress != nil && p.Spec.HTTPRoute != nil {
+		return fmt.Errorf("ingress and httpRoute are mutually exclusive")
+	}
+	if u := externalURL(p); u != "" {
+		if parsed, err := url.Parse(u); err != nil || !parsed.IsAbs() {
+			return fmt.Errorf("external URL %q is not an absolute URL", u)
+		}
+	}
+	if IsAgent(p) {
+		if len(p.Spec.RemoteWrite) == 0 {
+			return fmt.Errorf("agent mode requires at least one remoteWrite endpoint")
+		}
+		if p.Spec.Thanos != nil {
+			return fmt.Errorf("agent mode has no TSDB blocks to upload with thanos")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(p *monitoringv1alpha1.Prometheus)
+		wantErr string
+	}{
+		{
+			name:   "valid",
+			mutate: func(p *monitoringv1alpha1.Prometheus) {},
+		},
+		{
+			name: "thanos without externalLabels",
+			mutate: func(p *monitoringv1alpha1.Prometheus) {
+				p.Spec.Thanos = &monitoringv1alpha1.ThanosSpec{}
+			},
+			wantErr: "thanos requires externalLabels",
+		},
+		{
+			name: "thanos with externalLabels",
+			mutate: func(p *monitoringv1alpha1.Prometheus) {
+				p.Spec.Thanos = &monitoringv1alpha1.ThanosSpec{}
+				p.Spec.ExternalLabels = map[string]string{"cluster": "test"}
+			},
+		},
+		{
+			name: "thanos in agent mode",
+			mutate: func(p *monitoringv1alpha1.Prometheus) {
+				p.Spec.Mode = monitoringv1alpha1.ModeAgent
+				p.Spec.RemoteWrite = []monitoringv1alpha1.RemoteWriteSpec{{URL: "https://remote.example.org/write"}}
+				p.Spec.Thanos = &monitoringv1alpha1.ThanosSpec{}
+				p.Spec.ExternalLabels = map[string]string{"cluster": "test"}
+			},
+			wantErr: "agent mode has no TSDB blocks",
+		},
+		{
+			name: "shard external label of a sharded Prometheus",
+			mutate: func(p *monitoringv1alpha1.Prometheus) {
+				shards := int32(2)
+				p.Spec.Shards = &shards
+				p.Spec.ExternalLabels = map[string]string{"shard": "a"}
+			},
+			wantErr: `external label "shard" is set by the operator`,
+		},
+		{
+			name: "shard external label of an unsharded Prometheus",
+			mutate: func(p *monitoringv1alpha1.Prometheus) {
+				p.Spec.ExternalLabels = map[string]string{"shard": "a"}
+			},
+		},
+		{
+			name: "podDisruptionBudget minAvailable and maxUnavailable",
+			mutate: func(p *monitoringv1alpha1.Prometheus) {
+				one := intstr.FromInt(1)
+				p.Spec.PodDisruptionBudget = &monitoringv1alpha1.PodDisruptionBudgetSpec{MinAvailable: &one, MaxUnavailable: &one}
+			},
+			wantErr: "mutually exclusive",
+		},
+		{
+			name: "legacy job name",
+			mutate: func(p *monitoringv1alpha1.Prometheus) {
+				p.Spec.Targets = []monitoringv1alpha1.PrometheusTarget{{Targets: []string{"node:9100"}, JobName: legacyJobName}}
+			},
+			wantErr: `targets job name "gs" is already used`,
+		},
+		{
+			name: "job named after a preset",
+			mutate: func(p *monitoringv1alpha1.Prometheus) {
+				p.Spec.Presets.Kubelet = true
+				p.Spec.AdditionalScrapeConfig[0].JobName = kubeletJobName
+			},
+			wantErr: `job name "kubelet" is used more than once`,
+		},
+		{
+			name: "attachMetadata of ingresses",
+			mutate: func(p *monitoringv1alpha1.Prometheus) {
+				p.Spec.AdditionalScrapeConfig[0].KubernetesSDConfigs = []monitoringv1alpha1.KubernetesSDConfig{
+					{Role: monitoringv1alpha1.KubernetesRoleIngress, AttachMetadata: &monitoringv1alpha1.AttachMetadata{Node: true}},
+				}
+			},
+			wantErr: "attachMetadata is not supported by the ingress role",
+		},
+		{
+			name: "http_sd_configs before v2.21.0",
+			mutate: func(p *monitoringv1alpha1.Prometheus) {
+				p.Spec.Image.Version = "v2.20.0"
+				p.Spec.AdditionalScrapeConfig[0].HTTPSDConfigs = []monitoringv1alpha1.HTTPSDConfig{{URL: "https://sd.example.org"}}
+			},
+			wantErr: "requires Prometheus v2.21.0",
+		},
+		{
+			name: "targets of a job with conflicting scrape options",
+			mutate: func(p *monitoringv1alpha1.Prometheus) {
+				p.Spec.Targets = []monitoringv1alpha1.PrometheusTarget{
+					{Targets: []string{"a:80"}, JobName: "web", ScrapeInterval: "30s"},
+					{Targets: []string{"b:80"}, JobName: "web", MetricsPath: "/stats"},
+					{Targets: []string{"c:80"}, JobName: "web", ScrapeInterval: "1m"},
+				}
+			},
+			wantErr: `targets of job "web" set conflicting scrape options`,
+		},
+		{
+			name: "fileSDConfigMaps job name of a scrape config",
+			mutate: func(p *monitoringv1alpha1.Prometheus) {
+				p.Spec.FileSDConfigMaps = []monitoringv1alpha1.FileSDConfigMap{{Name: "team-a", JobName: "static"}}
+			},
+			wantErr: `fileSDConfigMaps job name "static" is already used`,
+		},
+		{
+			name: "fileSDConfigMaps ConfigMap listed twice",
+			mutate: func(p *monitoringv1alpha1.Prometheus) {
+				p.Spec.FileSDConfigMaps = []monitoringv1alpha1.FileSDConfigMap{{Name: "team-a"}, {Name: "team-a", JobName: "b"}}
+			},
+			wantErr: `ConfigMap "team-a" is listed more than once`,
+		},
+		{
+			name: "federate with static targets",
+			mutate: func(p *monitoringv1alpha1.Prometheus) {
+				p.Spec.AdditionalScrapeConfig[0].Federate = &monitoringv1alpha1.FederationSpec{
+					Prometheus: monitoringv1alpha1.PrometheusReference{Namespace: "team-a", Name: "prometheus"},
+				}
+			},
+			wantErr: "federate excludes any other target",
+		},
+		{
+			name: "kubelet preset behind a NetworkPolicy",
+			mutate: func(p *monitoringv1alpha1.Prometheus) {
+				p.Spec.NetworkPolicy = &monitoringv1alpha1.NetworkPolicySpec{}
+				p.Spec.Presets.Kubelet = true
+			},
+			wantErr: "requires networkPolicy.egressCIDRs",
+		},
+		{
+			name: "kubelet preset behind a NetworkPolicy with egressCIDRs",
+			mutate: func(p *monitoringv1alpha1.Prometheus) {
+				p.Spec.NetworkPolicy = &monitoringv1alpha1.NetworkPolicySpec{EgressCIDRs: []string{"10.0.0.0/16"}}
+				p.Spec.Presets = monitoringv1alpha1.PresetsSpec{Kubelet: true, APIServer: true, CoreDNS: true}
+			},
+		},
+		{
+			name: "kube-state-metrics preset behind a NetworkPolicy",
+			mutate: func(p *monitoringv1alpha1.Prometheus) {
+				p.Spec.NetworkPolicy = &monitoringv1alpha1.NetworkPolicySpec{}
+				p.Spec.Presets.KubeStateMetrics = true
+			},
+			wantErr: "requires networkPolicy.egressNamespaces",
+		},
+		{
+			name: "basic auth over TLS without CA",
+			mutate: func(p *monitoringv1alpha1.Prometheus) {
+				p.Spec.Web = &monitoringv1alpha1.WebSpec{TLS: newTestWebTLS(), BasicAuthUsers: &corev1.LocalObjectReference{Name: "users"}}
+				p.Spec.Web.TLS.CA = nil
+			},
+			wantErr: "web.tls.ca is required",
+		},
+		{
+			name: "thanos over TLS",
+			mutate: func(p *monitoringv1alpha1.Prometheus) {
+				p.Spec.Thanos = &monitoringv1alpha1.ThanosSpec{}
+				p.Spec.ExternalLabels = map[string]string{"cluster": "test"}
+				p.Spec.Web = &monitoringv1alpha1.WebSpec{TLS: newTestWebTLS()}
+			},
+			wantErr: "thanos cannot query a Prometheus served over web.tls",
+		},
+		{
+			name: "thanos with basic auth",
+			mutate: func(p *monitoringv1alpha1.Prometheus) {
+				p.Spec.Thanos = &monitoringv1alpha1.ThanosSpec{}
+				p.Spec.ExternalLabels = map[string]string{"cluster": "test"}
+				p.Spec.Web = &monitoringv1alpha1.WebSpec{BasicAuthUsers: &corev1.LocalObjectReference{Name: "users"}}
+			},
+			wantErr: "requiring basicAuthUsers",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPrometheus("v2.47.0")
+			tt.mutate(p)
+			err := Validate(p)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
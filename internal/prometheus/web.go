@@ -0,0 +1,328 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	WebConfigSecretSuffix = "-web-config"
+	// ReloaderUsername basic auth user the reload sidecar and the operator use to reload Prometheus
+	ReloaderUsername = "prometheus-operator"
+	// ReloaderPasswordKey key of the generated web config Secret holding the reloader password
+	ReloaderPasswordKey = "reloader-password"
+
+	webConfigKey       = "web-config.yml"
+	webConfigDir       = "/etc/prometheus/web"
+	webTLSDir          = "/etc/prometheus/web-tls"
+	webConfigVolume    = "web-config"
+	webTLSVolume       = "web-tls"
+	webTLSCertFile     = "tls.crt"
+	webTLSKeyFile      = "tls.key"
+	webTLSClientCAFile = "client-ca.crt"
+	webTLSCAFile       = "ca.crt"
+)
+
+type webConfig struct {
+	TLSServerConfig *webTLSServerConfig `yaml:"tls_server_config,omitempty"`
+	BasicAuthUsers  yaml.MapSlice       `yaml:"basic_auth_users,omitempty"`
+}
+
+type webTLSServerConfig struct {
+	CertFile       string `yaml:"cert_file"`
+	KeyFile        string `yaml:"key_file"`
+	ClientCAFile   string `yaml:"client_ca_file,omitempty"`
+	ClientAuthType string `yaml:"client_auth_type,omitempty"`
+}
+
+// HasWebConfig returns whether the web server is configured with TLS or basic auth,
+// which is rendered into a web config file.
+func HasWebConfig(p *monitoringv1alpha1.Prometheus) bool {
+	return p.Spec.Web != nil && (p.Spec.Web.TLS != nil || p.Spec.Web.BasicAuthUsers != nil)
+}
+
+// HasBasicAuth returns whether the web server requires basic auth.
+func HasBasicAuth(p *monitoringv1alpha1.Prometheus) bool {
+	return p.Spec.Web != nil && p.Spec.Web.BasicAuthUsers != nil
+}
+
+// HasWebTLS returns whether the web server is served over HTTPS.
+func HasWebTLS(p *monitoringv1alpha1.Prometheus) bool {
+	return p.Spec.Web != nil && p.Spec.Web.TLS != nil
+}
+
+func webScheme(p *monitoringv1alpha1.Prometheus) corev1.URIScheme {
+	if HasWebTLS(p) {
+		return corev1.URISchemeHTTPS
+	}
+	return corev1.URISchemeHTTP
+}
+
+func clientAuthType(p *monitoringv1alpha1.Prometheus) string {
+	t := p.Spec.Web.TLS
+	switch {
+	case t.ClientAuthType != "":
+		return t.ClientAuthType
+	case t.ClientCA != nil:
+		return "VerifyClientCertIfGiven"
+	}
+	return ""
+}
+
+// ReloadStrategy returns the strategy reloading the configuration. The sidecar reaches Prometheus
+// on localhost, which the serving certificate does not cover, so the operator reloads Prometheus
+// served over TLS.
+func ReloadStrategy(p *monitoringv1alpha1.Prometheus) string {
+	if HasWebTLS(p) {
+		return monitoringv1alpha1.ConfigReloaderOperator
+	}
+	if p.Spec.ConfigReloader.Strategy == "" {
+		return monitoringv1alpha1.ConfigReloaderSidecar
+	}
+	return p.Spec.ConfigReloader.Strategy
+}
+
+// ServerName returns the name the serving certificate is verified for.
+func ServerName(p *monitoringv1alpha1.Prometheus) string {
+	if HasWebTLS(p) && p.Spec.Web.TLS.ServerName != "" {
+		return p.Spec.Web.TLS.ServerName
+	}
+	return fmt.Sprintf("%s.%s.svc", p.Name, p.Namespace)
+}
+
+// ReplicaTLSConfig returns the TLS config verifying the replicas against the PEM encoded CA.
+// Replicas are reached by pod IP, so their certificate is verified for the server name.
+func ReplicaTLSConfig(p *monitoringv1alpha1.Prometheus, caPEM []byte) (*tls.Config, error) {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no PEM encoded certificate in the web TLS CA")
+	}
+	return &tls.Config{RootCAs: pool, ServerName: ServerName(p)}, nil
+}
+
+// externalURL returns the URL Prometheus is reachable at, if known. It defaults to the
+// URL of the Ingress or HTTPRoute exposing Prometheus.
+func externalURL(p *monitoringv1alpha1.Prometheus) string {
+	if p.Spec.Web != nil && p.Spec.Web.ExternalURL != "" {
+		return p.Spec.Web.ExternalURL
+	}
+	return exposedURL(p)
+}
+
+// routePrefix returns the path prefix of the web endpoints, without trailing slash.
+func routePrefix(p *monitoringv1alpha1.Prometheus) string {
+	prefix := ""
+	if p.Spec.Web != nil {
+		prefix = p.Spec.Web.RoutePrefix
+	}
+	if prefix == "" {
+		if u, err := url.Parse(externalURL(p)); err == nil {
+			prefix = u.Path
+		}
+	}
+	prefix = strings.TrimRight(prefix, "/")
+	if prefix != "" && !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	return prefix
+}
+
+func webArgs(p *monitoringv1alpha1.Prometheus) []string {
+	var args []string
+	if HasWebConfig(p) {
+		args = append(args, "--web.config.file="+webConfigDir+"/"+webConfigKey)
+	}
+	if u := externalURL(p); u != "" {
+		args = append(args, "--web.external-url="+u)
+	}
+	if p.Spec.Web != nil && p.Spec.Web.RoutePrefix != "" {
+		args = append(args, "--web.route-prefix="+p.Spec.Web.RoutePrefix)
+	}
+	if p.Spec.Web != nil && p.Spec.Web.PageTitle != "" {
+		args = append(args, "--web.page-title="+p.Spec.Web.PageTitle)
+	}
+	return args
+}
+
+// probeHandler returns the handler probing path on the web server. Probes cannot authenticate,
+// so the port is only checked when basic auth or client certificates are required.
+func probeHandler(p *monitoringv1alpha1.Prometheus, path string) corev1.ProbeHandler {
+	if HasBasicAuth(p) || (HasWebTLS(p) && clientAuthType(p) == "RequireAndVerifyClientCert") {
+		return corev1.ProbeHandler{
+			TCPSocket: &corev1.TCPSocketAction{
+				Port: intstr.FromInt(prometheusPort),
+			},
+		}
+	}
+	return corev1.ProbeHandler{
+		HTTPGet: &corev1.HTTPGetAction{
+			Path:   routePrefix(p) + path,
+			Port:   intstr.FromInt(prometheusPort),
+			Scheme: webScheme(p),
+		},
+	}
+}
+
+func webVolumes(p *monitoringv1alpha1.Prometheus) []corev1.Volume {
+	if !HasWebConfig(p) {
+		return nil
+	}
+	items := []corev1.KeyToPath{{Key: webConfigKey, Path: webConfigKey}}
+	if HasBasicAuth(p) && p.Spec.SelfMonitor {
+		// The self-scrape job authenticates like the reloader
+		items = append(items, corev1.KeyToPath{Key: ReloaderPasswordKey, Path: ReloaderPasswordKey})
+	}
+	v := []corev1.Volume{
+		{
+			Name: webConfigVolume,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: p.Name + WebConfigSecretSuffix,
+					Items:      items,
+				},
+			},
+		},
+	}
+
+	if HasWebTLS(p) {
+		t := p.Spec.Web.TLS
+		sources := []corev1.VolumeProjection{
+			secretProjection(t.Cert, webTLSCertFile),
+			secretProjection(t.Key, webTLSKeyFile),
+		}
+		if t.ClientCA != nil {
+			sources = append(sources, secretProjection(*t.ClientCA, webTLSClientCAFile))
+		}
+		if t.CA != nil {
+			// The self-scrape job verifies the replicas
+			sources = append(sources, secretProjection(*t.CA, webTLSCAFile))
+		}
+		v = append(v, corev1.Volume{
+			Name: webTLSVolume,
+			VolumeSource: corev1.VolumeSource{
+				Projected: &corev1.ProjectedVolumeSource{Sources: sources},
+			},
+		})
+	}
+	return v
+}
+
+func secretProjection(s corev1.SecretKeySelector, path string) corev1.VolumeProjection {
+	return corev1.VolumeProjection{
+		Secret: &corev1.SecretProjection{
+			LocalObjectReference: s.LocalObjectReference,
+			Items:                []corev1.KeyToPath{{Key: s.Key, Path: path}},
+		},
+	}
+}
+
+func webVolumeMounts(p *monitoringv1alpha1.Prometheus) []corev1.VolumeMount {
+	if !HasWebConfig(p) {
+		return nil
+	}
+	m := []corev1.VolumeMount{{Name: webConfigVolume, MountPath: webConfigDir, ReadOnly: true}}
+	if HasWebTLS(p) {
+		m = append(m, corev1.VolumeMount{Name: webTLSVolume, MountPath: webTLSDir, ReadOnly: true})
+	}
+	return m
+}
+
+// reloaderPasswordEnv returns the environment variable exposing the reloader password to the reload sidecar.
+func reloaderPasswordEnv(p *monitoringv1alpha1.Prometheus) corev1.EnvVar {
+	return corev1.EnvVar{
+		Name: "RELOADER_PASSWORD",
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: p.Name + WebConfigSecretSuffix},
+				Key:                  ReloaderPasswordKey,
+			},
+		},
+	}
+}
+
+// NewReloaderPassword returns a random password for the reloader user.
+func NewReloaderPassword() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// DesiredWebConfigSecret returns the Secret holding the web config file, rendered from the
+// basic auth users Secret data. The reloader user is added with reloaderPassword when basic auth is used.
+// currentHash is the reloader password hash already rendered, kept when it still matches the password.
+func DesiredWebConfigSecret(p *monitoringv1alpha1.Prometheus, users map[string][]byte, reloaderPassword string, currentHash string) (corev1.Secret, error) {
+	cfg := webConfig{}
+	if HasWebTLS(p) {
+		cfg.TLSServerConfig = &webTLSServerConfig{
+			CertFile:       webTLSDir + "/" + webTLSCertFile,
+			KeyFile:        webTLSDir + "/" + webTLSKeyFile,
+			ClientAuthType: clientAuthType(p),
+		}
+		if p.Spec.Web.TLS.ClientCA != nil {
+			cfg.TLSServerConfig.ClientCAFile = webTLSDir + "/" + webTLSClientCAFile
+		}
+	}
+
+	data := map[string][]byte{}
+	if HasBasicAuth(p) {
+		names := make([]string, 0, len(users))
+		for name := range users {
+			if name != ReloaderUsername {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			cfg.BasicAuthUsers = append(cfg.BasicAuthUsers, yaml.MapItem{Key: name, Value: string(users[name])})
+		}
+
+		// bcrypt hashes are salted, only hash the password again when it changed
+		hash := currentHash
+		if hash == "" || bcrypt.CompareHashAndPassword([]byte(hash), []byte(reloaderPassword)) != nil {
+			h, err := bcrypt.GenerateFromPassword([]byte(reloaderPassword), bcrypt.DefaultCost)
+			if err != nil {
+				return corev1.Secret{}, fmt.Errorf("unable to hash the reloader password, %v", err)
+			}
+			hash = string(h)
+		}
+		cfg.BasicAuthUsers = append(cfg.BasicAuthUsers, yaml.MapItem{Key: ReloaderUsername, Value: hash})
+		data[ReloaderPasswordKey] = []byte(reloaderPassword)
+	}
+
+	yamlData, err := yaml.Marshal(&cfg)
+	if err != nil {
+		return corev1.Secret{}, fmt.Errorf("unable to Marshal web config, %v", err)
+	}
+	data[webConfigKey] = yamlData
+
+	return corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: p.Name + WebConfigSecretSuffix, Namespace: p.Namespace, Labels: labels(p.Name)},
+		Data:       data,
+	}, nil
+}
+
+// ReloaderPasswordHash returns the reloader password hash rendered in a web config Secret.
+func ReloaderPasswordHash(s *corev1.Secret) string {
+	var cfg struct {
+		BasicAuthUsers map[string]string `yaml:"basic_auth_users"`
+	}
+	if err := yaml.Unmarshal(s.Data[webConfigKey], &cfg); err != nil {
+		return ""
+	}
+	return cfg.BasicAuthUsers[ReloaderUsername]
+}
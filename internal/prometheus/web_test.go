@@ -0,0 +1,220 @@
+package controllers
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	monitoringv1alpha1 "github.com/mcbenjemaa/gs-prometheus-operator/api/v1alpha1"
+	"golang.org/x/crypto/bcrypt"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func newTestWebTLS() *monitoringv1alpha1.WebTLSSpec {
+	secret := corev1.LocalObjectReference{Name: "web-tls"}
+	return &monitoringv1alpha1.WebTLSSpec{
+		Cert: corev1.SecretKeySelector{LocalObjectReference: secret, Key: "tls.crt"},
+		Key:  corev1.SecretKeySelector{LocalObjectReference: secret, Key: "tls.key"},
+		CA:   &corev1.SecretKeySelector{LocalObjectReference: secret, Key: "ca.crt"},
+	}
+}
+
+func TestDesiredWebConfigSecret(t *testing.T) {
+	p := newTestPrometheus("v2.47.0")
+	p.Spec.Web = &monitoringv1alpha1.WebSpec{
+		TLS:            newTestWebTLS(),
+		BasicAuthUsers: &corev1.LocalObjectReference{Name: "users"},
+	}
+	users := map[string][]byte{
+		"zoe":            []byte("$2y$zoe"),
+		"alice":          []byte("$2y$alice"),
+		ReloaderUsername: []byte("overridden"),
+	}
+
+	secret, err := DesiredWebConfigSecret(p, users, "secret", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := ReloaderPasswordHash(&secret)
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte("secret")); err != nil {
+		t.Fatalf("reloader hash does not match the password: %v", err)
+	}
+	want := `tls_server_config:
+  cert_file: /etc/prometheus/web-tls/tls.crt
+  key_file: /etc/prometheus/web-tls/tls.key
+basic_auth_users:
+  alice: $2y$alice
+  zoe: $2y$zoe
+  prometheus-operator: ` + hash + `
+`
+	if diff := cmp.Diff(want, string(secret.Data[webConfigKey])); diff != "" {
+		t.Errorf("unexpected web config (-want +got):\n%s", diff)
+	}
+	if string(secret.Data[ReloaderPasswordKey]) != "secret" {
+		t.Errorf("reloader password not stored, got %q", secret.Data[ReloaderPasswordKey])
+	}
+
+	// The hash is kept while it matches the password, bcrypt salting every hash
+	again, err := DesiredWebConfigSecret(p, users, "secret", hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(secret.Data, again.Data); diff != "" {
+		t.Errorf("web config changed for the same password (-want +got):\n%s", diff)
+	}
+	changed, err := DesiredWebConfigSecret(p, users, "rotated", hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(ReloaderPasswordHash(&changed)), []byte("rotated")); err != nil {
+		t.Errorf("reloader hash not updated for the new password: %v", err)
+	}
+}
+
+func TestDesiredWebConfigSecretTLSOnly(t *testing.T) {
+	p := newTestPrometheus("v2.47.0")
+	p.Spec.Web = &monitoringv1alpha1.WebSpec{TLS: newTestWebTLS()}
+	p.Spec.Web.TLS.ClientCA = &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "clients"}, Key: "ca.crt"}
+
+	secret, err := DesiredWebConfigSecret(p, nil, "secret", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `tls_server_config:
+  cert_file: /etc/prometheus/web-tls/tls.crt
+  key_file: /etc/prometheus/web-tls/tls.key
+  client_ca_file: /etc/prometheus/web-tls/client-ca.crt
+  client_auth_type: VerifyClientCertIfGiven
+`
+	if diff := cmp.Diff(want, string(secret.Data[webConfigKey])); diff != "" {
+		t.Errorf("unexpected web config (-want +got):\n%s", diff)
+	}
+	if _, ok := secret.Data[ReloaderPasswordKey]; ok {
+		t.Error("reloader password stored without basic auth")
+	}
+}
+
+func TestRoutePrefix(t *testing.T) {
+	tests := []struct {
+		name string
+		web  *monitoringv1alpha1.WebSpec
+		want string
+	}{
+		{name: "unset"},
+		{name: "external URL path", web: &monitoringv1alpha1.WebSpec{ExternalURL: "https://example.org/prometheus/"}, want: "/prometheus"},
+		{name: "route prefix wins", web: &monitoringv1alpha1.WebSpec{ExternalURL: "https://example.org/prometheus", RoutePrefix: "api/"}, want: "/api"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPrometheus("v2.47.0")
+			p.Spec.Web = tt.web
+			if got := routePrefix(p); got != tt.want {
+				t.Errorf("routePrefix() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProbeHandler(t *testing.T) {
+	p := newTestPrometheus("v2.47.0")
+	p.Spec.Web = &monitoringv1alpha1.WebSpec{TLS: newTestWebTLS(), RoutePrefix: "/prometheus"}
+	h := probeHandler(p, "/-/ready")
+	if h.HTTPGet == nil || h.HTTPGet.Path != "/prometheus/-/ready" || h.HTTPGet.Scheme != corev1.URISchemeHTTPS {
+		t.Errorf("expected an HTTPS probe of /prometheus/-/ready, got %+v", h)
+	}
+
+	// Probes cannot authenticate
+	p.Spec.Web.BasicAuthUsers = &corev1.LocalObjectReference{Name: "users"}
+	if h := probeHandler(p, "/-/ready"); h.TCPSocket == nil {
+		t.Errorf("expected a TCP probe with basic auth, got %+v", h)
+	}
+}
+
+func TestReloadStrategy(t *testing.T) {
+	p := newTestPrometheus("v2.47.0")
+	if got := ReloadStrategy(p); got != monitoringv1alpha1.ConfigReloaderSidecar {
+		t.Errorf("default strategy = %q, want sidecar", got)
+	}
+
+	// The sidecar cannot verify the certificate on localhost
+	p.Spec.Web = &monitoringv1alpha1.WebSpec{TLS: newTestWebTLS()}
+	p.Spec.ConfigReloader.Strategy = monitoringv1alpha1.ConfigReloaderSidecar
+	if got := ReloadStrategy(p); got != monitoringv1alpha1.ConfigReloaderOperator {
+		t.Errorf("strategy with TLS = %q, want operator", got)
+	}
+	for _, c := range containers(p) {
+		if c.Name == "configmap-reload" {
+			t.Error("reload sidecar added with TLS")
+		}
+	}
+}
+
+func TestReplicaTLSConfig(t *testing.T) {
+	p := newTestPrometheus("v2.47.0")
+	p.Spec.Web = &monitoringv1alpha1.WebSpec{TLS: newTestWebTLS()}
+
+	if _, err := ReplicaTLSConfig(p, []byte("not a certificate")); err == nil {
+		t.Error("expected an invalid CA to be rejected")
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	cfg, err := ReplicaTLSConfig(p, caPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.InsecureSkipVerify || cfg.RootCAs == nil {
+		t.Error("expected the replicas to be verified against the CA")
+	}
+	if cfg.ServerName != "test.monitoring.svc" {
+		t.Errorf("server name = %q, want the Service name", cfg.ServerName)
+	}
+
+	p.Spec.Web.TLS.ServerName = "prometheus.example.org"
+	if cfg, _ := ReplicaTLSConfig(p, caPEM); cfg.ServerName != "prometheus.example.org" {
+		t.Errorf("server name = %q, want prometheus.example.org", cfg.ServerName)
+	}
+}
+
+func TestWebVolumes(t *testing.T) {
+	p := newTestPrometheus("v2.47.0")
+	if webVolumes(p) != nil || webVolumeMounts(p) != nil {
+		t.Error("web volumes added without web config")
+	}
+
+	p.Spec.Web = &monitoringv1alpha1.WebSpec{TLS: newTestWebTLS()}
+	volumes := webVolumes(p)
+	if len(volumes) != 2 || volumes[1].Name != webTLSVolume {
+		t.Fatalf("expected the web config and TLS volumes, got %+v", volumes)
+	}
+	var paths []string
+	for _, source := range volumes[1].Projected.Sources {
+		paths = append(paths, source.Secret.Items[0].Path)
+	}
+	if diff := cmp.Diff([]string{webTLSCertFile, webTLSKeyFile, webTLSCAFile}, paths); diff != "" {
+		t.Errorf("unexpected TLS files (-want +got):\n%s", diff)
+	}
+}